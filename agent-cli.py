@@ -24,7 +24,7 @@ def print_task_details(task):
     task_desc = task.get("task", "No description")
     
     # Determine color based on status
-    color = "green" if status == "completed" else "yellow" if status == "processing" else "red" if status == "failed" else "blue"
+    color = "green" if status in ("completed", "planned") else "yellow" if status == "processing" else "red" if status == "failed" else "blue"
     
     # Create a panel with task information
     console.print(Panel(
@@ -135,7 +135,7 @@ def list_tasks():
         table.add_column("Created")
         
         for task in tasks:
-            status_style = "green" if task.get("status") == "completed" else "yellow" if task.get("status") == "processing" else "red"
+            status_style = "green" if task.get("status") in ("completed", "planned") else "yellow" if task.get("status") == "processing" else "red"
             table.add_row(
                 task.get("request_id", "Unknown")[:8] + "...",
                 task.get("task", "Unknown"),
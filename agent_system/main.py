@@ -1,5 +1,6 @@
 from flask import Flask, request, jsonify
 import os
+import platform
 import requests
 import logging
 
@@ -12,6 +13,12 @@ app = Flask(__name__)
 # Get Knowledge System URL from environment variable
 KNOWLEDGE_SYSTEM_URL = os.getenv("KNOWLEDGE_SYSTEM_URL", "http://knowledge-system:8085")
 
+# Build information, passed in as the VERSION, GIT_COMMIT and BUILD_DATE
+# Docker build args
+VERSION = os.getenv("VERSION", "dev")
+GIT_COMMIT = os.getenv("GIT_COMMIT", "dev")
+BUILD_DATE = os.getenv("BUILD_DATE", "dev")
+
 @app.route("/process", methods=["POST"])
 def process_instruction():
     try:
@@ -44,7 +51,16 @@ def process_instruction():
 
 @app.route("/health", methods=["GET"])
 def health_check():
-    return jsonify({"status": "healthy"})
+    return jsonify({"status": "healthy", "version": VERSION, "git_commit": GIT_COMMIT})
+
+@app.route("/version", methods=["GET"])
+def version():
+    return jsonify({
+        "version": VERSION,
+        "git_commit": GIT_COMMIT,
+        "build_date": BUILD_DATE,
+        "python_version": platform.python_version()
+    })
 
 if __name__ == "__main__":
     logger.info("Starting Agent System on port 8082")
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/linux-agent-system/api-gateway/pkg/taskclient"
+	"vm_manager/pkg/vmclient"
+)
+
+const usage = `Usage: agentctl [flags] <command> [args]
+
+Commands:
+  task submit "<instruction>"   Submit an instruction to the gateway
+  task status <id>              Show the status of a task
+  vm list                       List VMs known to the VM manager
+  vm ssh <id>                   Open an SSH session to a VM
+
+Flags:
+`
+
+// options holds the global flags shared by every command
+type options struct {
+	gatewayURL   string
+	vmManagerURL string
+	token        string
+	sshUser      string
+	jsonOutput   bool
+	timeout      time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	opts, rest, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(rest) < 2 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	switch rest[0] {
+	case "task":
+		err = runTask(ctx, opts, rest[1:], stdout)
+	case "vm":
+		err = runVM(ctx, opts, rest[1:], stdout)
+	default:
+		err = fmt.Errorf("unknown command %q", rest[0])
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "agentctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseFlags(args []string, stderr io.Writer) (*options, []string, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet("agentctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.gatewayURL, "gateway", envOr("AGENT_GATEWAY_URL", "http://localhost:8080"), "API gateway URL (env AGENT_GATEWAY_URL)")
+	fs.StringVar(&opts.vmManagerURL, "vm-manager", envOr("VM_MANAGER_URL", "http://localhost:8083"), "VM manager URL (env VM_MANAGER_URL)")
+	fs.StringVar(&opts.token, "token", os.Getenv("AGENT_TOKEN"), "bearer token sent to the services (env AGENT_TOKEN)")
+	fs.StringVar(&opts.sshUser, "ssh-user", envOr("VM_SSH_USER", "agent"), "user for vm ssh (env VM_SSH_USER)")
+	fs.BoolVar(&opts.jsonOutput, "json", false, "print JSON instead of a table")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "overall request timeout")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	return opts, fs.Args(), nil
+}
+
+func runTask(ctx context.Context, opts *options, args []string, stdout io.Writer) error {
+	client := taskclient.New(opts.gatewayURL)
+	client.Token = opts.token
+
+	switch args[0] {
+	case "submit":
+		if len(args) < 2 || strings.TrimSpace(strings.Join(args[1:], " ")) == "" {
+			return errors.New("task submit requires an instruction")
+		}
+		task, err := client.SubmitTask(ctx, taskclient.Instruction{Task: strings.Join(args[1:], " ")})
+		if err != nil {
+			return err
+		}
+		return printTask(stdout, opts.jsonOutput, task)
+	case "status":
+		if len(args) != 2 {
+			return errors.New("task status requires a task ID")
+		}
+		task, err := client.GetTask(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return printTask(stdout, opts.jsonOutput, task)
+	}
+	return fmt.Errorf("unknown task command %q", args[0])
+}
+
+func runVM(ctx context.Context, opts *options, args []string, stdout io.Writer) error {
+	client := vmclient.New(opts.vmManagerURL)
+	client.Token = opts.token
+
+	switch args[0] {
+	case "list":
+		vms, err := client.ListVMs(ctx)
+		if err != nil {
+			return err
+		}
+		return printVMs(stdout, opts.jsonOutput, vms)
+	case "ssh":
+		if len(args) != 2 {
+			return errors.New("vm ssh requires a VM ID")
+		}
+		vm, err := client.GetVM(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if len(vm.IPAddress) == 0 {
+			return fmt.Errorf("vm %s has no IP address", vm.ID)
+		}
+
+		cmd := exec.Command("ssh", fmt.Sprintf("%s@%s", opts.sshUser, vm.IPAddress[0]))
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+	return fmt.Errorf("unknown vm command %q", args[0])
+}
+
+func printTask(w io.Writer, asJSON bool, t *taskclient.TaskResponse) error {
+	if asJSON {
+		return writeJSON(w, t)
+	}
+
+	started := "-"
+	if !t.StartedAt.IsZero() {
+		started = t.StartedAt.Format(time.RFC3339)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tSTARTED\tMESSAGE")
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.RequestID, t.Status, started, t.Message)
+	return tw.Flush()
+}
+
+func printVMs(w io.Writer, asJSON bool, vms []vmclient.VM) error {
+	if asJSON {
+		return writeJSON(w, vms)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tIP")
+	for _, vm := range vms {
+		ip := "-"
+		if len(vm.IPAddress) > 0 {
+			ip = strings.Join(vm.IPAddress, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", vm.ID, vm.Name, vm.Status, ip)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
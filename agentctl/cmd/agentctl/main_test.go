@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newStubServices starts a gateway and a VM manager stub and returns flags
+// pointing agentctl at them
+func newStubServices(t *testing.T) []string {
+	t.Helper()
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/tasks":
+			var in struct {
+				Task string `json:"task"`
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			json.NewEncoder(w).Encode(map[string]string{
+				"request_id": "req-1",
+				"status":     "pending",
+				"message":    "accepted: " + in.Task,
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/tasks/req-1":
+			json.NewEncoder(w).Encode(map[string]string{
+				"request_id": "req-1",
+				"status":     "completed",
+				"started_at": "2024-01-02T03:04:05Z",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(gateway.Close)
+
+	vmManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vms":
+			w.Write([]byte(`{"vms":[{"id":"vm1","name":"openSUSE-1","status":"running","ipAddress":["192.168.122.100"]},{"id":"vm2","name":"openSUSE-2","status":"stopped"}]}`))
+		case "/vms/vm2":
+			w.Write([]byte(`{"id":"vm2","name":"openSUSE-2","status":"stopped"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(vmManager.Close)
+
+	return []string{"--gateway", gateway.URL, "--vm-manager", vmManager.URL}
+}
+
+func runCLI(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code = run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// assertRows compares table output row by row, ignoring column padding
+func assertRows(t *testing.T, output string, want []string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d rows, want %d:\n%s", len(lines), len(want), output)
+	}
+	for i, line := range lines {
+		if got := strings.Join(strings.Fields(line), " "); got != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{nil, 2, "Usage: agentctl"},
+		{[]string{"task"}, 2, "Usage: agentctl"},
+		{[]string{"--bogus", "vm", "list"}, 2, "flag provided but not defined"},
+		{[]string{"-h"}, 0, "Usage: agentctl"},
+		{[]string{"frob", "x"}, 1, `unknown command "frob"`},
+		{[]string{"task", "frob"}, 1, `unknown task command "frob"`},
+		{[]string{"task", "submit"}, 1, "task submit requires an instruction"},
+		{[]string{"task", "status"}, 1, "task status requires a task ID"},
+		{[]string{"vm", "ssh"}, 1, "vm ssh requires a VM ID"},
+	}
+	for _, tt := range tests {
+		code, _, stderr := runCLI(t, tt.args...)
+		if code != tt.code || !strings.Contains(stderr, tt.want) {
+			t.Errorf("agentctl %q = %d, %q; want %d and %q", tt.args, code, stderr, tt.code, tt.want)
+		}
+	}
+}
+
+func TestFlagDefaultsFromEnv(t *testing.T) {
+	t.Setenv("AGENT_GATEWAY_URL", "http://gateway.example")
+	t.Setenv("AGENT_TOKEN", "secret")
+
+	opts, rest, err := parseFlags([]string{"--json", "vm", "list"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.gatewayURL != "http://gateway.example" || opts.token != "secret" || !opts.jsonOutput {
+		t.Errorf("options = %+v", opts)
+	}
+	if opts.vmManagerURL != "http://localhost:8083" || opts.sshUser != "agent" {
+		t.Errorf("defaults = %+v", opts)
+	}
+	if strings.Join(rest, " ") != "vm list" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestTaskSubmitTable(t *testing.T) {
+	flags := newStubServices(t)
+	code, stdout, stderr := runCLI(t, append(flags, "task", "submit", "install", "nginx")...)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+
+	want := []string{
+		"ID STATUS STARTED MESSAGE",
+		"req-1 pending - accepted: install nginx",
+	}
+	assertRows(t, stdout, want)
+}
+
+func TestTaskStatusJSON(t *testing.T) {
+	flags := newStubServices(t)
+	code, stdout, stderr := runCLI(t, append(flags, "--json", "task", "status", "req-1")...)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+
+	var task map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &task); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+	if task["request_id"] != "req-1" || task["status"] != "completed" || task["started_at"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("task = %v", task)
+	}
+}
+
+func TestTaskStatusNotFound(t *testing.T) {
+	flags := newStubServices(t)
+	code, _, stderr := runCLI(t, append(flags, "task", "status", "missing")...)
+	if code != 1 || !strings.Contains(stderr, "404") {
+		t.Errorf("exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestVMListTable(t *testing.T) {
+	flags := newStubServices(t)
+	code, stdout, stderr := runCLI(t, append(flags, "vm", "list")...)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+
+	want := []string{
+		"ID NAME STATUS IP",
+		"vm1 openSUSE-1 running 192.168.122.100",
+		"vm2 openSUSE-2 stopped -",
+	}
+	assertRows(t, stdout, want)
+}
+
+func TestVMListJSON(t *testing.T) {
+	flags := newStubServices(t)
+	code, stdout, stderr := runCLI(t, append(flags, "--json", "vm", "list")...)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+
+	var vms []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &vms); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+	if len(vms) != 2 || vms[0]["id"] != "vm1" || vms[1]["status"] != "stopped" {
+		t.Errorf("vms = %v", vms)
+	}
+}
+
+func TestVMSSHWithoutIP(t *testing.T) {
+	flags := newStubServices(t)
+	code, _, stderr := runCLI(t, append(flags, "vm", "ssh", "vm2")...)
+	if code != 1 || !strings.Contains(stderr, "vm vm2 has no IP address") {
+		t.Errorf("exit %d, stderr %q", code, stderr)
+	}
+}
@@ -0,0 +1,13 @@
+module agentctl
+
+go 1.21
+
+require (
+	github.com/user/linux-agent-system/api-gateway v0.0.0
+	vm_manager v0.0.0
+)
+
+replace (
+	github.com/user/linux-agent-system/api-gateway => ../api-gateway
+	vm_manager => ../vm_manager
+)
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -22,6 +24,9 @@ type Instruction struct {
 	RequestID   string `json:"request_id,omitempty"`
 }
 
+// maxBodyBytes bounds request bodies accepted by the task handlers
+var maxBodyBytes int64 = 1 << 20
+
 type TaskResponse struct {
 	RequestID   string      `json:"request_id"`
 	Status      string      `json:"status"`
@@ -42,23 +47,21 @@ func main() {
 		port = "8080"
 	}
 
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			maxBodyBytes = n
+		} else {
+			log.Printf("Ignoring invalid MAX_REQUEST_BODY_BYTES %q", value)
+		}
+	}
+
 	// Configure router
-	r := mux.NewRouter()
-	
-	// API Routes
-	api := r.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/tasks", submitTaskHandler).Methods("POST")
-	api.HandleFunc("/tasks/{requestId}", getTaskStatusHandler).Methods("GET")
-	api.HandleFunc("/tasks/{requestId}", cancelTaskHandler).Methods("DELETE")
-	api.HandleFunc("/health", healthCheckHandler).Methods("GET")
-	
-	// Prometheus metrics
-	r.Handle("/metrics", promhttp.Handler())
+	r := newRouter()
 
 	// HTTP server configuration
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      r,
+		Handler:      stripTrailingSlash(r),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -90,10 +93,34 @@ func main() {
 	log.Println("Server exited properly")
 }
 
+// newRouter registers the API, metrics and version routes
+func newRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	// API Routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/tasks", submitTaskHandler).Methods("POST")
+	api.HandleFunc("/tasks/{requestId}", getTaskStatusHandler).Methods("GET")
+	api.HandleFunc("/tasks/{requestId}", cancelTaskHandler).Methods("DELETE")
+	api.HandleFunc("/health", healthCheckHandler).Methods("GET")
+
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler())
+	r.HandleFunc("/version", versionHandler).Methods("GET")
+	configureRouter(r)
+	return r
+}
+
 func submitTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var instruction Instruction
 	
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	if err := json.NewDecoder(r.Body).Decode(&instruction); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
@@ -165,7 +192,8 @@ func cancelTaskHandler(w http.ResponseWriter, r *http.Request) {
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
-		"version": "1.0.0",
+		"status":     "healthy",
+		"version":    version,
+		"git_commit": gitCommit,
 	})
 }
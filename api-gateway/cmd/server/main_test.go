@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmitTaskHandlerBodyLimit(t *testing.T) {
+	saved := maxBodyBytes
+	maxBodyBytes = 64
+	t.Cleanup(func() { maxBodyBytes = saved })
+
+	oversized := `{"task":"` + strings.Repeat("a", 100) + `"}`
+	rec := httptest.NewRecorder()
+	submitTaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(oversized)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	submitTaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(`{"task":"list files"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("small body: status %d, want 202", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	submitTaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(`{"task":`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve sends a request through the router the way main does
+func serve(method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	stripTrailingSlash(newRouter()).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method, path, allow string
+	}{
+		// Routes on the /api/v1 subrouter
+		{http.MethodGet, "/api/v1/tasks", "POST"},
+		{http.MethodPut, "/api/v1/tasks/req-1", "GET, DELETE"},
+		// Routes on the root router
+		{http.MethodPost, "/version", "GET"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.method, tt.path)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want 405", tt.method, tt.path, rec.Code)
+			continue
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+		var body struct {
+			Error          string   `json:"error"`
+			AllowedMethods []string `json:"allowed_methods"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != "method not allowed" {
+			t.Errorf("%s %s: body = %s", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestNotFoundIsJSON(t *testing.T) {
+	for _, path := range []string{"/nope", "/api/v1/nope"} {
+		rec := serve(http.MethodGet, path)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, rec.Code)
+			continue
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["path"] != path {
+			t.Errorf("GET %s: body = %s", path, rec.Body.String())
+		}
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	for _, path := range []string{"/api/v1/health/", "/version/"} {
+		if rec := serve(http.MethodGet, path); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", path, rec.Code)
+		}
+	}
+}
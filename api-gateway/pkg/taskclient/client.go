@@ -0,0 +1,193 @@
+// Package taskclient provides a typed client for the API Gateway task endpoints.
+package taskclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sentinel errors returned (wrapped in an *APIError) for well-known statuses
+var (
+	ErrBadRequest  = errors.New("invalid task request")
+	ErrNotFound    = errors.New("task not found")
+	ErrUnavailable = errors.New("gateway unavailable")
+)
+
+// Instruction is the payload used to submit a task
+type Instruction struct {
+	Task      string `json:"task"`
+	Priority  string `json:"priority,omitempty"`
+	Timeout   int    `json:"timeout,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// TaskResponse mirrors the gateway's task representation
+type TaskResponse struct {
+	RequestID   string      `json:"request_id"`
+	Status      string      `json:"status"`
+	Message     string      `json:"message,omitempty"`
+	Details     interface{} `json:"details,omitempty"`
+	StartedAt   time.Time   `json:"started_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
+
+// APIError describes a non-2xx response from the gateway
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gateway returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap maps the status code to one of the sentinel errors so callers can
+// use errors.Is.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusServiceUnavailable, e.StatusCode == http.StatusBadGateway:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Client talks to the API Gateway
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// Token, when set, is sent as a bearer token on every request.
+	Token string
+
+	// GetRetries is the number of additional attempts made for GET requests
+	// that fail with a transport error or a 5xx status.
+	GetRetries int
+	RetryDelay time.Duration
+}
+
+// New creates a client for the gateway at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		GetRetries: 2,
+		RetryDelay: 500 * time.Millisecond,
+	}
+}
+
+// SubmitTask submits a new instruction for processing
+func (c *Client) SubmitTask(ctx context.Context, instruction Instruction) (*TaskResponse, error) {
+	body, err := json.Marshal(instruction)
+	if err != nil {
+		return nil, err
+	}
+
+	var task TaskResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/tasks", body, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTask returns the current status of a task
+func (c *Client) GetTask(ctx context.Context, requestID string) (*TaskResponse, error) {
+	path := "/api/v1/tasks/" + url.PathEscape(requestID)
+
+	var err error
+	for attempt := 0; attempt <= c.GetRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.RetryDelay * time.Duration(attempt)):
+			}
+		}
+
+		// Decode into a fresh value so a failed attempt can't leak fields
+		var task TaskResponse
+		err = c.do(ctx, http.MethodGet, path, nil, &task)
+		if err == nil {
+			return &task, nil
+		}
+		if !retryable(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// CancelTask cancels a pending or running task
+func (c *Client) CancelTask(ctx context.Context, requestID string) (*TaskResponse, error) {
+	var task TaskResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/tasks/"+url.PathEscape(requestID), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// retryable reports whether a failed GET is worth another attempt
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
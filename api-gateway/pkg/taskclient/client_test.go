@@ -0,0 +1,162 @@
+package taskclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient points a client at a stub gateway with fast retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := New(server.URL)
+	client.RetryDelay = time.Millisecond
+	return client
+}
+
+func TestSubmitTask(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/tasks" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		var in Instruction
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		json.NewEncoder(w).Encode(TaskResponse{RequestID: "req-1", Status: "pending", Message: in.Task})
+	})
+
+	task, err := client.SubmitTask(context.Background(), Instruction{Task: "install nginx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.RequestID != "req-1" || task.Status != "pending" || task.Message != "install nginx" {
+		t.Errorf("SubmitTask = %+v", task)
+	}
+}
+
+func TestSubmitTaskBadRequest(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Task is required", http.StatusBadRequest)
+	})
+
+	_, err := client.SubmitTask(context.Background(), Instruction{})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestGetTaskRetriesServerErrors(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(TaskResponse{RequestID: "req-1", Status: "completed"})
+	})
+
+	task, err := client.GetTask(context.Background(), "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "completed" || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("GetTask = %+v after %d calls", task, calls)
+	}
+}
+
+func TestGetTaskGivesUpAfterRetries(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+
+	_, err := client.GetTask(context.Background(), "req-1")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != int32(client.GetRetries+1) {
+		t.Errorf("calls = %d, want %d", got, client.GetRetries+1)
+	}
+}
+
+func TestGetTaskDoesNotRetryNotFound(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.NotFound(w, r)
+	})
+
+	_, err := client.GetTask(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestGetTaskDecodesFreshValuePerAttempt(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Fails to decode after request_id has been filled in
+			w.Write([]byte(`{"request_id":"stale","started_at":"not a time"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"completed"}`))
+	})
+
+	task, err := client.GetTask(context.Background(), "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.RequestID != "" || task.Status != "completed" {
+		t.Errorf("GetTask = %+v, want only the second response", task)
+	}
+}
+
+func TestGetTaskHonorsContext(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	client.RetryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetTask(ctx, "req-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCancelTaskSendsToken(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/tasks/req-1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		json.NewEncoder(w).Encode(TaskResponse{RequestID: "req-1", Status: "cancelled"})
+	})
+	client.Token = "secret"
+
+	task, err := client.CancelTask(context.Background(), "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "cancelled" {
+		t.Errorf("CancelTask = %+v", task)
+	}
+}
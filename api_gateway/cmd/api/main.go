@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"bytes"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -27,25 +30,40 @@ func main() {
 	}
 	logger.Infof("Using Orchestrator URL: %s", orchestratorURL)
 
-	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/tasks", createTaskHandler(orchestratorURL)).Methods("POST")
-	router.HandleFunc("/api/v1/tasks/{id}", getTaskHandler(orchestratorURL)).Methods("GET")
-	router.HandleFunc("/api/v1/instructions", submitInstructionHandler(orchestratorURL)).Methods("POST")
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	maxBodyBytes := maxBodyBytesFromEnv()
+
+	router := newRouter(orchestratorURL, maxBodyBytes)
 
 	port := "8080"
 	logger.Infof("API Gateway listening on port %s", port)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), router)
+	err := http.ListenAndServe(fmt.Sprintf(":%s", port), stripTrailingSlash(router))
 	if err != nil {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 }
 
-func createTaskHandler(orchestratorURL string) http.HandlerFunc {
+// newRouter registers the gateway routes, forwarding to orchestratorURL
+func newRouter(orchestratorURL string, maxBodyBytes int64) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/tasks", createTaskHandler(orchestratorURL, maxBodyBytes)).Methods("POST")
+	router.HandleFunc("/api/v1/tasks/{id}", getTaskHandler(orchestratorURL)).Methods("GET")
+	router.HandleFunc("/api/v1/instructions", submitInstructionHandler(orchestratorURL)).Methods("POST")
+	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	router.HandleFunc("/version", versionHandler).Methods("GET")
+	configureRouter(router)
+	return router
+}
+
+func createTaskHandler(orchestratorURL string, maxBodyBytes int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Read the request body
-		body, err := io.ReadAll(r.Body)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
 		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, "Failed to read request body", http.StatusBadRequest)
 			return
 		}
@@ -88,8 +106,20 @@ func getTaskHandler(orchestratorURL string) http.HandlerFunc {
 		vars := mux.Vars(r)
 		taskID := vars["id"]
 
-		// Forward the request to the orchestrator
-		resp, err := http.Get(fmt.Sprintf("%s/tasks/%s", orchestratorURL, taskID))
+		// Forward the request to the orchestrator, passing the API version through
+		target := fmt.Sprintf("%s/tasks/%s", orchestratorURL, taskID)
+		if version := r.URL.Query().Get("api_version"); version != "" {
+			target += "?api_version=" + url.QueryEscape(version)
+		}
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			http.Error(w, "Failed to forward request to orchestrator", http.StatusInternalServerError)
+			return
+		}
+		if version := r.Header.Get("X-API-Version"); version != "" {
+			req.Header.Set("X-API-Version", version)
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			logger.Errorf("Failed to forward request to orchestrator: %v", err)
 			http.Error(w, "Failed to forward request to orchestrator", http.StatusInternalServerError)
@@ -129,7 +159,23 @@ func submitInstructionHandler(orchestratorURL string) http.HandlerFunc {
 	}
 }
 
+// maxBodyBytesFromEnv returns the request body limit from MAX_REQUEST_BODY_BYTES
+func maxBodyBytesFromEnv() int64 {
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && n > 0 {
+			return n
+		}
+		logger.Warnf("Ignoring invalid MAX_REQUEST_BODY_BYTES %q", value)
+	}
+	return 1 << 20
+}
+
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "healthy",
+		"version":    version,
+		"git_commit": gitCommit,
+	})
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newStubOrchestrator counts the task submissions it receives
+func newStubOrchestrator(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"task_id":"task-1","status":"pending"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCreateTaskHandlerBodyLimit(t *testing.T) {
+	var calls int32
+	orchestrator := newStubOrchestrator(t, &calls)
+	handler := createTaskHandler(orchestrator.URL, 64)
+
+	oversized := `{"instruction":"` + strings.Repeat("a", 100) + `"}`
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(oversized)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", rec.Code)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("oversized body was forwarded %d times", n)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(`{"instruction":"list files"}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("small body: status %d, want 200", rec.Code)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("small body was forwarded %d times, want 1", n)
+	}
+}
+
+func TestMaxBodyBytesFromEnv(t *testing.T) {
+	logger.SetOutput(io.Discard)
+	for value, want := range map[string]int64{"": 1 << 20, "2048": 2048, "-1": 1 << 20, "lots": 1 << 20} {
+		t.Setenv("MAX_REQUEST_BODY_BYTES", value)
+		if n := maxBodyBytesFromEnv(); n != want {
+			t.Errorf("MAX_REQUEST_BODY_BYTES=%q: got %d, want %d", value, n, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve sends a request through the gateway router the way main does
+func serve(method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router := newRouter("http://orchestrator.invalid", 1<<20)
+	stripTrailingSlash(router).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method, path, allow string
+	}{
+		{http.MethodGet, "/api/v1/tasks", "POST"},
+		{http.MethodDelete, "/api/v1/tasks/task-1", "GET"},
+		{http.MethodPost, "/health", "GET"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.method, tt.path)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want 405", tt.method, tt.path, rec.Code)
+			continue
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "method not allowed" {
+			t.Errorf("%s %s: body = %s", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestNotFoundIsJSON(t *testing.T) {
+	rec := serve(http.MethodGet, "/api/v2/tasks")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["path"] != "/api/v2/tasks" {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	if rec := serve(http.MethodGet, "/health/"); rec.Code != http.StatusOK {
+		t.Errorf("GET /health/: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build information, injected at build time:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.gitCommit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	gitCommit = "dev"
+	buildDate = "dev"
+)
+
+// buildInfo is the payload of GET /version
+type buildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONStatus(w, r, http.StatusOK, currentBuildInfo())
+}
@@ -15,10 +15,40 @@ struct CommandResponse {
     exit_code: i32,
 }
 
+// Build information, baked in at compile time from the VERSION, GIT_COMMIT
+// and BUILD_DATE Docker build args
+const VERSION: &str = match option_env!("VERSION") {
+    Some(v) => v,
+    None => "dev",
+};
+const GIT_COMMIT: &str = match option_env!("GIT_COMMIT") {
+    Some(v) => v,
+    None => "dev",
+};
+const BUILD_DATE: &str = match option_env!("BUILD_DATE") {
+    Some(v) => v,
+    None => "dev",
+};
+
+#[derive(Debug, Serialize)]
+struct VersionResponse {
+    version: &'static str,
+    git_commit: &'static str,
+    build_date: &'static str,
+}
+
 async fn health_check() -> impl Responder {
     HttpResponse::Ok().body("Command Executor service is healthy")
 }
 
+async fn version() -> impl Responder {
+    HttpResponse::Ok().json(VersionResponse {
+        version: VERSION,
+        git_commit: GIT_COMMIT,
+        build_date: BUILD_DATE,
+    })
+}
+
 async fn execute_command(command_req: web::Json<CommandRequest>) -> impl Responder {
     let output = Command::new("sh")
         .arg("-c")
@@ -62,6 +92,7 @@ async fn main() -> std::io::Result<()> {
     HttpServer::new(|| {
         App::new()
             .route("/health", web::get().to(health_check))
+            .route("/version", web::get().to(version))
             .route("/execute", web::post().to(execute_command))
     })
     .bind("0.0.0.0:8084")?
@@ -4,6 +4,7 @@ from typing import List, Optional, Dict, Any
 import logging
 import os
 import json
+import platform
 from datetime import datetime
 
 # Configure logging
@@ -13,6 +14,12 @@ logger = logging.getLogger(__name__)
 # Initialize FastAPI app
 app = FastAPI(title="Knowledge System")
 
+# Build information, passed in as the VERSION, GIT_COMMIT and BUILD_DATE
+# Docker build args
+VERSION = os.getenv("VERSION", "dev")
+GIT_COMMIT = os.getenv("GIT_COMMIT", "dev")
+BUILD_DATE = os.getenv("BUILD_DATE", "dev")
+
 # Define Pydantic models for request and response validation
 class SearchQuery(BaseModel):
     query: str
@@ -101,16 +108,27 @@ init_documentation()
 
 @app.get("/")
 async def root():
-    return {"status": "Knowledge System operational", "version": "1.0.0"}
+    return {"status": "Knowledge System operational", "version": VERSION}
 
 @app.get("/health")
 async def health_check():
     return {
         "status": "healthy", 
+        "version": VERSION,
+        "git_commit": GIT_COMMIT,
         "doc_count": len(opensuse_docs),
         "storage": os.path.exists(DOCS_FILE)
     }
 
+@app.get("/version")
+async def version():
+    return {
+        "version": VERSION,
+        "git_commit": GIT_COMMIT,
+        "build_date": BUILD_DATE,
+        "python_version": platform.python_version()
+    }
+
 @app.post("/search", response_model=SearchResponse)
 async def search_documentation(query: SearchQuery):
     """
@@ -1,6 +1,7 @@
 from flask import Flask, request, jsonify
 import logging
 import os
+import platform
 
 # Configure logging
 logging.basicConfig(level=logging.INFO, format='%(asctime)s - %(name)s - %(levelname)s - %(message)s')
@@ -8,6 +9,12 @@ logger = logging.getLogger(__name__)
 
 app = Flask(__name__)
 
+# Build information, passed in as the VERSION, GIT_COMMIT and BUILD_DATE
+# Docker build args
+VERSION = os.getenv("VERSION", "dev")
+GIT_COMMIT = os.getenv("GIT_COMMIT", "dev")
+BUILD_DATE = os.getenv("BUILD_DATE", "dev")
+
 # Initialize an in-memory knowledge store for demonstration
 knowledge_store = {
     "system_commands": {
@@ -66,7 +73,16 @@ def query_knowledge():
 
 @app.route("/health", methods=["GET"])
 def health_check():
-    return jsonify({"status": "healthy"})
+    return jsonify({"status": "healthy", "version": VERSION, "git_commit": GIT_COMMIT})
+
+@app.route("/version", methods=["GET"])
+def version():
+    return jsonify({
+        "version": VERSION,
+        "git_commit": GIT_COMMIT,
+        "build_date": BUILD_DATE,
+        "python_version": platform.python_version()
+    })
 
 if __name__ == "__main__":
     logger.info("Starting Knowledge System on port 8085")
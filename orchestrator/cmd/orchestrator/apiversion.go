@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response shapes are versioned so clients can pin the fields they expect.
+// Version 1 is the original task shape; version 2 adds placement, plan,
+// dependency, deadline and replay fields.
+const (
+	minAPIVersion    = 1
+	latestAPIVersion = 2
+)
+
+// defaultAPIVersion is used when a request names no version
+var defaultAPIVersion = latestAPIVersion
+
+// versioned is implemented by responses whose shape depends on the API version
+type versioned interface {
+	forAPIVersion(version int) interface{}
+}
+
+// taskV1 is the version 1 task shape
+type taskV1 struct {
+	ID          string      `json:"id"`
+	Instruction string      `json:"instruction"`
+	Status      string      `json:"status"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+func (t *Task) forAPIVersion(version int) interface{} {
+	if version >= 2 {
+		return t
+	}
+	return taskV1{
+		ID:          t.ID,
+		Instruction: t.Instruction,
+		Status:      t.Status,
+		Result:      t.Result,
+		Error:       t.Error,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// taskList is a list of tasks rendered in the requested version
+type taskList []*Task
+
+func (l taskList) forAPIVersion(version int) interface{} {
+	out := make([]interface{}, 0, len(l))
+	for _, t := range l {
+		out = append(out, t.forAPIVersion(version))
+	}
+	return out
+}
+
+// requestedAPIVersion reads the api_version query parameter or the
+// X-API-Version header ("2" or "v2"), falling back to defaultAPIVersion
+func requestedAPIVersion(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("api_version")
+	if value == "" {
+		value = r.Header.Get("X-API-Version")
+	}
+	if value == "" {
+		return defaultAPIVersion, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "v"))
+	if err != nil || version < minAPIVersion || version > latestAPIVersion {
+		return 0, fmt.Errorf("unsupported api_version %q (supported: %d-%d)", value, minAPIVersion, latestAPIVersion)
+	}
+	return version, nil
+}
+
+// apiVersionKey is the request context key holding the resolved API version
+type apiVersionKey struct{}
+
+// apiVersionMiddleware resolves the requested API version before the handler
+// runs. Unsupported versions are rejected up front so a request never takes
+// effect only to fail when its response is rendered.
+func apiVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := requestedAPIVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+	})
+}
+
+// apiVersion returns the version resolved by apiVersionMiddleware, or
+// defaultAPIVersion for requests that matched no route
+func apiVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return defaultAPIVersion
+}
+
+// applyAPIVersion renders v, and the values of a map payload, in the given version
+func applyAPIVersion(v interface{}, version int) interface{} {
+	switch payload := v.(type) {
+	case versioned:
+		return payload.forAPIVersion(version)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(payload))
+		for key, value := range payload {
+			if vv, ok := value.(versioned); ok {
+				value = vv.forAPIVersion(version)
+			}
+			out[key] = value
+		}
+		return out
+	}
+	return v
+}
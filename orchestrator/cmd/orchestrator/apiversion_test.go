@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnsupportedAPIVersionHasNoSideEffects(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPost, "/tasks?api_version=9", map[string]interface{}{"instruction": "install nginx"}},
+		{http.MethodPost, "/admin/pause?api_version=v0", nil},
+		{http.MethodPut, "/tasks/task-1/artifacts/out.log?api_version=x", "data"},
+	}
+	for _, tt := range tests {
+		if rec := doRequest(t, tt.method, tt.path, tt.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", tt.method, tt.path, rec.Code)
+		}
+	}
+
+	taskMutex.RLock()
+	saved := len(TaskStore)
+	taskMutex.RUnlock()
+	if saved != 1 {
+		t.Errorf("%d tasks stored, want only the existing one", saved)
+	}
+	if paused, _ := dispatch.State(); paused {
+		t.Error("dispatching was paused by a rejected request")
+	}
+	if list, _ := artifacts.List("task-1"); len(list) != 0 {
+		t.Errorf("artifacts = %+v, want none", list)
+	}
+}
+
+func TestAPIVersionSelectsTaskShape(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+
+	tests := []struct {
+		name    string
+		path    string
+		header  string
+		version string
+		v2      bool
+	}{
+		{"default", "/tasks/task-1", "", "2", true},
+		{"query", "/tasks/task-1?api_version=1", "", "1", false},
+		{"header", "/tasks/task-1", "v1", "1", false},
+		{"query wins", "/tasks/task-1?api_version=2", "1", "2", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("X-API-Version", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		stripTrailingSlash(newRouter()).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.name, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-API-Version"); got != tt.version {
+			t.Errorf("%s: X-API-Version = %q, want %q", tt.name, got, tt.version)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fields["owner"]; ok != tt.v2 {
+			t.Errorf("%s: owner present = %v, want %v", tt.name, ok, tt.v2)
+		}
+		if fields["id"] != "task-1" {
+			t.Errorf("%s: id = %v", tt.name, fields["id"])
+		}
+	}
+}
+
+func TestAPIVersionAppliesToLists(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+	deadLetters.Add("task-1")
+
+	rec := doRequest(t, http.MethodGet, "/tasks/deadletter?api_version=1", nil)
+	if strings.Contains(rec.Body.String(), `"owner"`) {
+		t.Errorf("v1 dead-letter list includes v2 fields: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// ErrArtifactNotFound is returned when a task has no artifact with the given name
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrArtifactTooLarge is returned when an upload would exceed the per-task cap
+	ErrArtifactTooLarge = errors.New("task artifact size limit exceeded")
+	// ErrInvalidArtifactName is returned for names that are not a plain file name
+	ErrInvalidArtifactName = errors.New("invalid artifact name")
+)
+
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Artifact describes a file stored for a task
+type Artifact struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	URL        string    `json:"url"`
+}
+
+// artifactStore keeps task artifacts on disk under dir/<task ID>/<name>.
+// Clients upload artifacts through the API; processTask only stores the
+// rendered plan. Command output is not captured because plans are not
+// executed yet.
+type artifactStore struct {
+	dir          string
+	maxTaskBytes int64
+	mutex        sync.Mutex
+}
+
+func newArtifactStore(dir string, maxTaskBytes int64) (*artifactStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %v", err)
+	}
+	return &artifactStore{dir: dir, maxTaskBytes: maxTaskBytes}, nil
+}
+
+// Save stores the artifact, replacing any previous artifact of the same name
+func (s *artifactStore) Save(taskID, name string, r io.Reader) (*Artifact, error) {
+	if !artifactNamePattern.MatchString(name) {
+		return nil, ErrInvalidArtifactName
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	taskDir := filepath.Join(s.dir, taskID)
+	if err := os.MkdirAll(taskDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// The existing file of the same name doesn't count against the budget
+	used, err := s.usage(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(filepath.Join(taskDir, name)); err == nil {
+		used -= info.Size()
+	}
+	remaining := s.maxTaskBytes - used
+
+	tmp, err := os.CreateTemp(taskDir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Read one byte past the budget so an oversized upload can be detected
+	written, err := io.Copy(tmp, io.LimitReader(r, remaining+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if written > remaining {
+		return nil, ErrArtifactTooLarge
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(taskDir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return newArtifact(taskID, info), nil
+}
+
+// List returns the task's artifacts sorted by name
+func (s *artifactStore) List(taskID string) ([]Artifact, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Artifact{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Artifact{}
+	for _, entry := range entries {
+		if entry.IsDir() || !artifactNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, *newArtifact(taskID, info))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Open returns the artifact file for reading
+func (s *artifactStore) Open(taskID, name string) (*os.File, error) {
+	if !artifactNamePattern.MatchString(name) {
+		return nil, ErrInvalidArtifactName
+	}
+	f, err := os.Open(filepath.Join(s.dir, taskID, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+	return f, err
+}
+
+// usage returns the total size of the task's artifacts
+func (s *artifactStore) usage(taskID string) (int64, error) {
+	list, err := s.List(taskID)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, a := range list {
+		total += a.Size
+	}
+	return total, nil
+}
+
+func newArtifact(taskID string, info os.FileInfo) *Artifact {
+	return &Artifact{
+		Name:       info.Name(),
+		Size:       info.Size(),
+		ModifiedAt: info.ModTime().UTC(),
+		URL:        fmt.Sprintf("/tasks/%s/artifacts/%s", url.PathEscape(taskID), url.PathEscape(info.Name())),
+	}
+}
+
+// planArtifactName is the artifact processTask stores the rendered plan under
+const planArtifactName = "plan.sh"
+
+// savePlanArtifact stores the plan's commands as a shell script so the
+// planned changes can be reviewed or run by hand
+func savePlanArtifact(taskID string, plan *Plan) error {
+	script := "#!/bin/sh\nset -e\n" + strings.Join(plan.Commands(), "\n") + "\n"
+	_, err := artifacts.Save(taskID, planArtifactName, strings.NewReader(script))
+	return err
+}
+
+func uploadArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := getTask(vars["id"]); !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	artifact, err := artifacts.Save(vars["id"], vars["name"], r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidArtifactName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrArtifactTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			logger.Errorf("Failed to store artifact %s for task %s: %v", vars["name"], vars["id"], err)
+			http.Error(w, "Failed to store artifact", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeStatusResponse(w, r, http.StatusCreated, artifact)
+}
+
+func listArtifactsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	if _, ok := getTask(taskID); !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	list, err := artifacts.List(taskID)
+	if err != nil {
+		logger.Errorf("Failed to list artifacts for task %s: %v", taskID, err)
+		http.Error(w, "Failed to list artifacts", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, map[string]interface{}{"artifacts": list})
+}
+
+func downloadArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := getTask(vars["id"]); !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := artifacts.Open(vars["id"], vars["name"])
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidArtifactName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrArtifactNotFound):
+			http.Error(w, "Artifact not found", http.StatusNotFound)
+		default:
+			logger.Errorf("Failed to open artifact %s for task %s: %v", vars["name"], vars["id"], err)
+			http.Error(w, "Failed to open artifact", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Failed to open artifact", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveTestTask stores a finished task directly, bypassing the dispatcher
+func saveTestTask(t *testing.T, id string) {
+	t.Helper()
+	now := time.Now()
+	saveTask(&Task{ID: id, Instruction: "install nginx", Status: TaskStatusCompleted, Owner: replicaID, CreatedAt: now, UpdatedAt: now})
+}
+
+func TestArtifactUploadListDownload(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+
+	rec := doRequest(t, http.MethodPut, "/tasks/task-1/artifacts/output.log", "hello world\n")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var uploaded Artifact
+	if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.Name != "output.log" || uploaded.Size != 12 || uploaded.URL != "/tasks/task-1/artifacts/output.log" {
+		t.Errorf("uploaded = %+v", uploaded)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/tasks/task-1/artifacts", nil)
+	var list struct {
+		Artifacts []Artifact `json:"artifacts"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Artifacts) != 1 || list.Artifacts[0].Name != "output.log" {
+		t.Errorf("list = %+v", list.Artifacts)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/tasks/task-1/artifacts/output.log", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello world\n" {
+		t.Errorf("download returned %d: %q", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="output.log"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+}
+
+func TestArtifactErrors(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"upload to unknown task", http.MethodPut, "/tasks/missing/artifacts/a.txt", "data", http.StatusNotFound},
+		{"list unknown task", http.MethodGet, "/tasks/missing/artifacts", nil, http.StatusNotFound},
+		{"download unknown task", http.MethodGet, "/tasks/missing/artifacts/a.txt", nil, http.StatusNotFound},
+		{"download unknown artifact", http.MethodGet, "/tasks/task-1/artifacts/a.txt", nil, http.StatusNotFound},
+		{"upload hidden name", http.MethodPut, "/tasks/task-1/artifacts/.env", "data", http.StatusBadRequest},
+		{"download hidden name", http.MethodGet, "/tasks/task-1/artifacts/.env", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := doRequest(t, tt.method, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestArtifactSizeCap(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+	var err error
+	if artifacts, err = newArtifactStore(t.TempDir(), 16); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doRequest(t, http.MethodPut, "/tasks/task-1/artifacts/a.txt", "0123456789"); rec.Code != http.StatusCreated {
+		t.Fatalf("first upload returned %d", rec.Code)
+	}
+	// 10 + 7 bytes exceeds the 16 byte cap
+	if rec := doRequest(t, http.MethodPut, "/tasks/task-1/artifacts/b.txt", "0123456"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("over-cap upload returned %d, want 413", rec.Code)
+	}
+	// Replacing a.txt only counts the new size
+	if rec := doRequest(t, http.MethodPut, "/tasks/task-1/artifacts/a.txt", strings.Repeat("x", 16)); rec.Code != http.StatusCreated {
+		t.Errorf("replacing upload returned %d, want 201", rec.Code)
+	}
+
+	list, err := artifacts.List("task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "a.txt" || list[0].Size != 16 {
+		t.Errorf("list = %+v; a rejected upload must leave nothing behind", list)
+	}
+}
+
+func TestProcessTaskStoresPlanArtifact(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx and start it"})
+	waitForStatus(t, id, TaskStatusPlanned)
+
+	rec := doRequest(t, http.MethodGet, "/tasks/"+id+"/artifacts/"+planArtifactName, nil)
+	want := "#!/bin/sh\nset -e\nzypper --non-interactive install nginx\nsystemctl start nginx\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("plan artifact returned %d: %q, want %q", rec.Code, rec.Body.String(), want)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// deadLetterQueue holds the IDs of failed tasks, oldest first, until they are
+// replayed or evicted to make room for newer failures
+type deadLetterQueue struct {
+	mutex sync.Mutex
+	ids   []string
+	max   int
+}
+
+func newDeadLetterQueue(max int) *deadLetterQueue {
+	return &deadLetterQueue{max: max}
+}
+
+// Add appends a task, evicting the oldest entries beyond the cap
+func (q *deadLetterQueue) Add(id string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, existing := range q.ids {
+		if existing == id {
+			return
+		}
+	}
+	q.ids = append(q.ids, id)
+	if len(q.ids) > q.max {
+		evicted := q.ids[:len(q.ids)-q.max]
+		logger.Warnf("Dead-letter queue full, evicting %d oldest tasks", len(evicted))
+		q.ids = append([]string(nil), q.ids[len(q.ids)-q.max:]...)
+	}
+}
+
+// Contains reports whether the task is queued
+func (q *deadLetterQueue) Contains(id string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return containsString(q.ids, id)
+}
+
+// Remove takes a task off the queue and reports whether it was there
+func (q *deadLetterQueue) Remove(id string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, existing := range q.ids {
+		if existing == id {
+			q.ids = append(q.ids[:i], q.ids[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the queued task IDs, oldest first
+func (q *deadLetterQueue) List() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return append([]string(nil), q.ids...)
+}
+
+// loadDeadLetters rebuilds the queue from the cache after loadTasks
+func loadDeadLetters() {
+	var failed []*Task
+	taskMutex.RLock()
+	for _, task := range TaskStore {
+		if task.Status == TaskStatusFailed && task.ReplayedBy == "" {
+			snapshot := *task
+			failed = append(failed, &snapshot)
+		}
+	}
+	taskMutex.RUnlock()
+
+	sort.Slice(failed, func(i, j int) bool { return failed[i].UpdatedAt.Before(failed[j].UpdatedAt) })
+	for _, task := range failed {
+		deadLetters.Add(task.ID)
+	}
+}
+
+func listDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	tasks := taskList{}
+	for _, id := range deadLetters.List() {
+		if task, ok := getTask(id); ok {
+			tasks = append(tasks, task)
+		}
+	}
+	writeResponse(w, r, map[string]interface{}{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// replayTaskHandler submits a fresh copy of a dead-lettered task. The original
+// stays failed and records the ID of its replacement. Replays are rejected
+// while a dependency's latest attempt is still failed, since the copy would
+// fail again straight away.
+func replayTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	original, ok := getTask(id)
+	if !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !deadLetters.Contains(id) {
+		http.Error(w, "Task is not in the dead-letter queue", http.StatusConflict)
+		return
+	}
+
+	taskID := newTaskID()
+	dependsOn, err := replayDependencies(original)
+	if err == nil {
+		err = validateDependencies(taskID, dependsOn)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot replay task: %v", err), http.StatusConflict)
+		return
+	}
+	if !deadLetters.Remove(id) {
+		http.Error(w, "Task is not in the dead-letter queue", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	task := &Task{
+		ID:          taskID,
+		Instruction: original.Instruction,
+		Status:      TaskStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ReplayOf:    original.ID,
+		Owner:       replicaID,
+
+		AffinityGroup:     original.AffinityGroup,
+		AntiAffinityGroup: original.AntiAffinityGroup,
+		DependsOn:         dependsOn,
+		Runtime:           original.Runtime,
+	}
+	// Keep the original timeout rather than its already-passed deadline
+	timeout := defaultTaskTimeout
+	if original.DeadlineAt != nil {
+		timeout = original.DeadlineAt.Sub(original.CreatedAt)
+	}
+	deadline := now.Add(timeout)
+	task.DeadlineAt = &deadline
+
+	saveTask(task)
+	// getTask has cached the original, so update the shared copy
+	taskMutex.RLock()
+	cached := TaskStore[id]
+	taskMutex.RUnlock()
+	updateTask(cached, func(t *Task) {
+		t.ReplayedBy = task.ID
+	})
+	logger.Infof("Replaying dead-lettered task %s as %s", id, task.ID)
+
+	dispatch.Submit(task)
+	writeStatusResponse(w, r, http.StatusAccepted, map[string]string{
+		"task_id":   task.ID,
+		"replay_of": id,
+	})
+}
+
+// replayDependencies returns the task's dependencies with each one replaced by
+// its latest replay, so a dependent can be replayed after its failed
+// dependency has been. It fails if any latest attempt is still failed.
+func replayDependencies(original *Task) ([]string, error) {
+	var dependsOn []string
+	for _, dep := range original.DependsOn {
+		latest, ok := getTask(dep)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrDependencyNotFound, dep)
+		}
+		for latest.ReplayedBy != "" {
+			next, ok := getTask(latest.ReplayedBy)
+			if !ok {
+				break
+			}
+			latest = next
+		}
+		if latest.Status == TaskStatusFailed {
+			return nil, fmt.Errorf("%w: %s; replay it first", ErrDependencyFailed, latest.ID)
+		}
+		dependsOn = append(dependsOn, latest.ID)
+	}
+	return dependsOn, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// replayTask replays a dead-lettered task and returns the response
+func replayTask(t *testing.T, id string) (int, string) {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/tasks/"+id+"/replay", nil)
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.TaskID
+}
+
+// deadLetterIDs lists the dead-letter queue through the API
+func deadLetterIDs(t *testing.T) []string {
+	t.Helper()
+	rec := doRequest(t, http.MethodGet, "/tasks/deadletter", nil)
+	var resp struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, task := range resp.Tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+// unreachableManager returns the URL of a VM manager that is not listening
+func unreachableManager() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestFailedTaskIsDeadLetteredAndReplayed(t *testing.T) {
+	setupTest(t, unreachableManager())
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+	waitForStatus(t, id, TaskStatusFailed)
+	if ids := deadLetterIDs(t); len(ids) != 1 || ids[0] != id {
+		t.Fatalf("dead-letter queue = %v, want [%s]", ids, id)
+	}
+
+	// Bring a manager up and replay
+	dispatch.Wait()
+	scheduler = newVMScheduler([]string{newStubManager(t, nil, 0).URL})
+	code, replayID := replayTask(t, id)
+	if code != http.StatusAccepted || replayID == "" {
+		t.Fatalf("replay returned %d", code)
+	}
+
+	replay := waitForStatus(t, replayID, TaskStatusPlanned)
+	if replay.ReplayOf != id || replay.Instruction != "install nginx" {
+		t.Errorf("replay = %+v", replay)
+	}
+	if original, _ := getTask(id); original.Status != TaskStatusFailed || original.ReplayedBy != replayID {
+		t.Errorf("original is %s, replayed by %q", original.Status, original.ReplayedBy)
+	}
+	if ids := deadLetterIDs(t); len(ids) != 0 {
+		t.Errorf("dead-letter queue = %v after replay, want empty", ids)
+	}
+
+	if code, _ := replayTask(t, id); code != http.StatusConflict {
+		t.Errorf("second replay returned %d, want 409", code)
+	}
+	if code, _ := replayTask(t, "missing"); code != http.StatusNotFound {
+		t.Errorf("replay of unknown task returned %d, want 404", code)
+	}
+}
+
+func TestReplayWaitsForFailedDependency(t *testing.T) {
+	setupTest(t, unreachableManager())
+
+	dispatch.Pause()
+	first := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+	second := submitTask(t, map[string]interface{}{"instruction": "start nginx", "depends_on": []string{first}})
+	dispatch.Resume()
+	waitForStatus(t, first, TaskStatusFailed)
+	waitForStatus(t, second, TaskStatusFailed)
+
+	// The dependency is still failed, so replaying the dependent would fail again
+	if code, _ := replayTask(t, second); code != http.StatusConflict {
+		t.Fatalf("replay of dependent returned %d, want 409", code)
+	}
+	if ids := deadLetterIDs(t); len(ids) != 2 {
+		t.Errorf("dead-letter queue = %v, want both tasks kept", ids)
+	}
+
+	dispatch.Wait()
+	scheduler = newVMScheduler([]string{newStubManager(t, nil, 0).URL})
+	_, firstReplay := replayTask(t, first)
+	waitForStatus(t, firstReplay, TaskStatusPlanned)
+
+	code, secondReplay := replayTask(t, second)
+	if code != http.StatusAccepted {
+		t.Fatalf("replay of dependent returned %d after its dependency was replayed", code)
+	}
+	replay := waitForStatus(t, secondReplay, TaskStatusPlanned)
+	if len(replay.DependsOn) != 1 || replay.DependsOn[0] != firstReplay {
+		t.Errorf("replay depends on %v, want [%s]", replay.DependsOn, firstReplay)
+	}
+}
+
+func TestDeadLetterQueueEvictsOldestAndSurvivesRestart(t *testing.T) {
+	setupTest(t, unreachableManager())
+	deadLetters = newDeadLetterQueue(2)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		id := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+		waitForStatus(t, id, TaskStatusFailed)
+		ids = append(ids, id)
+	}
+	want := strings.Join(ids[2:], " ")
+	if got := strings.Join(deadLetterIDs(t), " "); got != want {
+		t.Fatalf("dead-letter queue = %s, want the newest two %s", got, want)
+	}
+
+	// Simulate a restart: rebuild the cache and the queue from the store
+	dispatch.Wait()
+	taskMutex.Lock()
+	TaskStore = make(map[string]*Task)
+	taskMutex.Unlock()
+	deadLetters = newDeadLetterQueue(2)
+	if err := loadTasks(); err != nil {
+		t.Fatal(err)
+	}
+	loadDeadLetters()
+
+	if got := strings.Join(deadLetterIDs(t), " "); got != want {
+		t.Errorf("dead-letter queue after restart = %s, want %s", got, want)
+	}
+}
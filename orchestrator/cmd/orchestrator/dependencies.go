@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrDependencyCycle is returned when depends_on would make a task wait on itself
+	ErrDependencyCycle = errors.New("dependency cycle")
+	// ErrDependencyNotFound is returned when depends_on names an unknown task
+	ErrDependencyNotFound = errors.New("dependency not found")
+	// ErrDependencyFailed is recorded on tasks whose dependency failed
+	ErrDependencyFailed = errors.New("dependency failed")
+)
+
+// validateDependencies checks a new task's depends_on list: every dependency
+// must exist and following the dependency edges must never lead back to a
+// task already on the path.
+func validateDependencies(taskID string, dependsOn []string) error {
+	seen := make(map[string]bool)
+	for _, dep := range dependsOn {
+		if dep == taskID {
+			return fmt.Errorf("%w: task depends on itself", ErrDependencyCycle)
+		}
+		if seen[dep] {
+			return fmt.Errorf("duplicate dependency %q", dep)
+		}
+		seen[dep] = true
+		if _, ok := getTask(dep); !ok {
+			return fmt.Errorf("%w: %s", ErrDependencyNotFound, dep)
+		}
+	}
+
+	// Depth-first search with the usual in-progress/done marking
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{taskID: visiting}
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append(path, id), " -> "))
+		case done:
+			return nil
+		}
+		state[id] = visiting
+
+		task, ok := getTask(id)
+		if ok {
+			for _, dep := range task.DependsOn {
+				if err := visit(dep, append(path, id)); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = done
+		return nil
+	}
+	for _, dep := range dependsOn {
+		if err := visit(dep, []string{taskID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependenciesReady reports whether every dependency of the task has
+// completed or been planned. It returns an error wrapping ErrDependencyFailed once any
+// dependency has failed, since the task can then never run.
+func dependenciesReady(task *Task) (bool, error) {
+	ready := true
+	for _, dep := range task.DependsOn {
+		t, ok := getTask(dep)
+		if !ok {
+			return false, fmt.Errorf("%w: %s", ErrDependencyNotFound, dep)
+		}
+		switch t.Status {
+		case TaskStatusFailed:
+			return false, fmt.Errorf("%w: %s", ErrDependencyFailed, dep)
+		case TaskStatusCompleted, TaskStatusPlanned:
+		default:
+			ready = false
+		}
+	}
+	return ready, nil
+}
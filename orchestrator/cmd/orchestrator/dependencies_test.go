@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDependentTaskWaitsForDependency(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	// Hold the dependency in the paused queue so the dependent has to wait
+	dispatch.Pause()
+	first := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+	second := submitTask(t, map[string]interface{}{"instruction": "start nginx", "depends_on": []string{first}})
+
+	if task, _ := getTask(second); task.Status != TaskStatusPending {
+		t.Fatalf("dependent task is %s, want pending", task.Status)
+	}
+	dispatch.Resume()
+
+	dep := waitForStatus(t, first, TaskStatusPlanned)
+	task := waitForStatus(t, second, TaskStatusPlanned)
+	if task.StartedAt == nil || task.StartedAt.Before(dep.UpdatedAt) {
+		t.Errorf("dependent started at %v, before its dependency completed at %v", task.StartedAt, dep.UpdatedAt)
+	}
+}
+
+func TestDependencyFailureCascades(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	dispatch.Pause()
+	first := submitTask(t, map[string]interface{}{"instruction": "make me a sandwich"})
+	second := submitTask(t, map[string]interface{}{"instruction": "install nginx", "depends_on": []string{first}})
+	third := submitTask(t, map[string]interface{}{"instruction": "start nginx", "depends_on": []string{second}})
+	dispatch.Resume()
+
+	waitForStatus(t, first, TaskStatusFailed)
+	for _, id := range []string{second, third} {
+		task := waitForStatus(t, id, TaskStatusFailed)
+		if !strings.Contains(task.Error, ErrDependencyFailed.Error()) {
+			t.Errorf("task %s error = %q, want %q", id, task.Error, ErrDependencyFailed)
+		}
+		if task.StartedAt != nil {
+			t.Errorf("task %s started although its dependency failed", id)
+		}
+	}
+	if ids := deadLetters.List(); len(ids) != 3 {
+		t.Errorf("dead-letter queue = %v, want all three tasks", ids)
+	}
+}
+
+func TestCreateTaskRejectsInvalidDependencies(t *testing.T) {
+	setupTest(t)
+	saveTestTask(t, "task-1")
+
+	for _, dependsOn := range [][]string{{"missing"}, {"task-1", "task-1"}} {
+		rec := doRequest(t, http.MethodPost, "/tasks", map[string]interface{}{"instruction": "install nginx", "depends_on": dependsOn})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("depends_on %v: status %d, want 400", dependsOn, rec.Code)
+		}
+	}
+}
+
+func TestValidateDependenciesDetectsCycles(t *testing.T) {
+	setupTest(t)
+
+	// The API can't create a cycle, so build one in the store: a -> b -> c -> a
+	now := time.Now()
+	for id, dep := range map[string]string{"a": "b", "b": "c", "c": "a"} {
+		saveTask(&Task{ID: id, Status: TaskStatusPending, DependsOn: []string{dep}, Owner: replicaID, CreatedAt: now, UpdatedAt: now})
+	}
+	saveTask(&Task{ID: "d", Status: TaskStatusCompleted, Owner: replicaID, CreatedAt: now, UpdatedAt: now})
+
+	tests := []struct {
+		taskID    string
+		dependsOn []string
+		wantErr   error
+	}{
+		{"new", []string{"d"}, nil},
+		{"new", []string{"new"}, ErrDependencyCycle},
+		{"new", []string{"a"}, ErrDependencyCycle},
+		{"c", []string{"a"}, ErrDependencyCycle},
+		{"new", []string{"d", "missing"}, ErrDependencyNotFound},
+	}
+	for _, tt := range tests {
+		err := validateDependencies(tt.taskID, tt.dependsOn)
+		if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("validateDependencies(%q, %v) = %v, want %v", tt.taskID, tt.dependsOn, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import "sync"
+
+// dispatcher hands new tasks to processTask. While paused, tasks are held in
+// submission order and dispatched once processing resumes. Tasks with
+// dependencies wait in pending until every dependency has completed.
+type dispatcher struct {
+	mutex   sync.Mutex
+	paused  bool
+	queue   []*Task
+	waiting []*Task
+	running sync.WaitGroup
+}
+
+// Submit starts processing the task, or holds it while paused or while its
+// dependencies are unfinished
+func (d *dispatcher) Submit(task *Task) {
+	d.mutex.Lock()
+	ready, err := dependenciesReady(task)
+	if err != nil {
+		d.mutex.Unlock()
+		d.fail(task, err)
+		return
+	}
+	if ready {
+		d.dispatchLocked(task)
+	} else {
+		d.waiting = append(d.waiting, task)
+	}
+	d.mutex.Unlock()
+}
+
+// Finished re-evaluates the tasks waiting on a task that just completed or
+// failed, dispatching those that are now ready and failing those that can
+// never run
+func (d *dispatcher) Finished(taskID string) {
+	d.mutex.Lock()
+	var failed []*Task
+	var errs []error
+	var remaining []*Task
+	for _, task := range d.waiting {
+		if !containsString(task.DependsOn, taskID) {
+			remaining = append(remaining, task)
+			continue
+		}
+		ready, err := dependenciesReady(task)
+		switch {
+		case err != nil:
+			failed = append(failed, task)
+			errs = append(errs, err)
+		case ready:
+			d.dispatchLocked(task)
+		default:
+			remaining = append(remaining, task)
+		}
+	}
+	d.waiting = remaining
+	d.mutex.Unlock()
+
+	for i, task := range failed {
+		d.fail(task, errs[i])
+	}
+}
+
+// fail marks a task that never ran as failed and propagates to its dependents
+func (d *dispatcher) fail(task *Task, err error) {
+	failTask(task, err)
+	d.Finished(task.ID)
+}
+
+// dispatchLocked starts the task or queues it while paused; d.mutex must be held
+func (d *dispatcher) dispatchLocked(task *Task) {
+	if d.paused {
+		d.queue = append(d.queue, task)
+		updateTask(task, func(t *Task) {
+			t.Status = TaskStatusQueued
+		})
+		return
+	}
+	d.start(task)
+}
+
+// start runs processTask in the background, tracked by d.running
+func (d *dispatcher) start(task *Task) {
+	d.running.Add(1)
+	go func() {
+		defer d.running.Done()
+		processTask(task)
+	}()
+}
+
+// Wait blocks until every task started so far has finished processing
+func (d *dispatcher) Wait() {
+	d.running.Wait()
+}
+
+// Pause stops dispatching new tasks; tasks already processing continue
+func (d *dispatcher) Pause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.paused = true
+}
+
+// Resume dispatches every queued task and returns how many were released
+func (d *dispatcher) Resume() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.paused = false
+	queued := d.queue
+	d.queue = nil
+	for _, task := range queued {
+		d.start(task)
+	}
+	return len(queued)
+}
+
+// State reports whether dispatching is paused and how many tasks are waiting
+func (d *dispatcher) State() (paused bool, queued int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.paused, len(d.queue)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPauseHoldsTasksUntilResume(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	if rec := doRequest(t, http.MethodPost, "/admin/pause", nil); rec.Code != http.StatusOK {
+		t.Fatalf("pause returned %d", rec.Code)
+	}
+
+	var ids []string
+	for _, instruction := range []string{"install nginx", "install curl", "install vim"} {
+		ids = append(ids, submitTask(t, map[string]interface{}{"instruction": instruction}))
+	}
+	for _, id := range ids {
+		if task, _ := getTask(id); task.Status != TaskStatusQueued {
+			t.Errorf("task %s is %s while paused, want queued", id, task.Status)
+		}
+	}
+
+	// Queued tasks are released in submission order
+	dispatch.mutex.Lock()
+	var queued []string
+	for _, task := range dispatch.queue {
+		queued = append(queued, task.ID)
+	}
+	dispatch.mutex.Unlock()
+	if len(queued) != len(ids) {
+		t.Fatalf("queue = %v, want %v", queued, ids)
+	}
+	for i := range ids {
+		if queued[i] != ids[i] {
+			t.Fatalf("queue = %v, want %v", queued, ids)
+		}
+	}
+
+	rec := doRequest(t, http.MethodPost, "/admin/resume", nil)
+	var resp struct {
+		Paused   bool `json:"paused"`
+		Released int  `json:"released"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Paused || resp.Released != len(ids) {
+		t.Errorf("resume = %+v, want %d released", resp, len(ids))
+	}
+	for _, id := range ids {
+		waitForStatus(t, id, TaskStatusPlanned)
+	}
+	if paused, n := dispatch.State(); paused || n != 0 {
+		t.Errorf("State() = %v, %d after resume", paused, n)
+	}
+}
+
+func TestPauseLetsRunningTasksFinish(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+	dispatch.Pause()
+	waitForStatus(t, id, TaskStatusPlanned)
+
+	queuedID := submitTask(t, map[string]interface{}{"instruction": "install curl"})
+	if _, n := dispatch.State(); n != 1 {
+		t.Errorf("%d tasks queued, want 1", n)
+	}
+	dispatch.Resume()
+	waitForStatus(t, queuedID, TaskStatusPlanned)
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -15,10 +20,14 @@ var logger = logrus.New()
 
 // Task status constants
 const (
-	TaskStatusPending   = "pending"
+	TaskStatusPending    = "pending"
+	TaskStatusQueued     = "queued"
 	TaskStatusProcessing = "processing"
 	TaskStatusCompleted  = "completed"
 	TaskStatusFailed     = "failed"
+	// TaskStatusPlanned marks a task whose plan was rendered and placed but
+	// not executed. It is terminal, like completed and failed.
+	TaskStatusPlanned = "planned"
 )
 
 // Task represents a task in the system
@@ -28,13 +37,71 @@ type Task struct {
 	Status      string      `json:"status"`
 	Result      interface{} `json:"result,omitempty"`
 	Error       string      `json:"error,omitempty"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	VMManager   string      `json:"vm_manager,omitempty"`
+	Plan        *Plan       `json:"plan,omitempty"`
+
+	// Placement hints: tasks sharing an affinity group are co-located when
+	// possible, tasks sharing an anti-affinity group never share a host. A
+	// task counts for its group until affinityTTL after it finished.
+	AffinityGroup     string `json:"affinity_group,omitempty"`
+	AntiAffinityGroup string `json:"anti_affinity_group,omitempty"`
+
+	// Runtime is the isolation runtime the task's VM manager must provide
+	Runtime string `json:"runtime,omitempty"`
+
+	// DependsOn lists tasks that must complete before this one is dispatched
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// ReplayOf and ReplayedBy link a dead-lettered task and its replay
+	ReplayOf   string `json:"replay_of,omitempty"`
+	ReplayedBy string `json:"replayed_by,omitempty"`
+
+	// Owner is the replica that dispatched the task
+	Owner     string    `json:"owner,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// DeadlineAt is CreatedAt plus the task's effective timeout. StartedAt is
+	// set when processing begins; ProcessingDuration is the time spent
+	// processing in seconds, live while the task is still running.
+	DeadlineAt         *time.Time `json:"deadline_at,omitempty"`
+	StartedAt          *time.Time `json:"started_at,omitempty"`
+	ProcessingDuration float64    `json:"processing_duration_seconds,omitempty"`
 }
 
-// TaskStore is a simple in-memory store for tasks
+// ErrDeadlineExceeded is recorded on tasks that did not finish before DeadlineAt
+var ErrDeadlineExceeded = errors.New("task deadline exceeded")
+
+// TaskStore is the in-memory cache of tasks, backed by store
 var TaskStore = make(map[string]*Task)
 
+var (
+	taskMutex    sync.RWMutex
+	store        Store
+	scheduler    *vmScheduler
+	parser       InstructionParser = newKeywordParser()
+	dispatch                       = &dispatcher{}
+	artifacts    *artifactStore
+	deadLetters  *deadLetterQueue
+	maxBodyBytes int64
+
+	// replicaID identifies this orchestrator among replicas sharing a store
+	replicaID string
+
+	// defaultTaskTimeout applies to tasks submitted without a timeout
+	defaultTaskTimeout = 30 * time.Minute
+
+	// placementMaxWait bounds how long a task waits for busy VM managers
+	placementMaxWait = 5 * time.Minute
+
+	// affinityTTL is how long a finished task keeps pinning its host for
+	// affinity and anti-affinity placement
+	affinityTTL = time.Hour
+)
+
+// busyRetryDelay is the placement retry interval when managers send no Retry-After
+const busyRetryDelay = 5 * time.Second
+
 func main() {
 	logger.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
@@ -46,108 +113,516 @@ func main() {
 	if agentSystemURL == "" {
 		agentSystemURL = "http://agent-system:8082"
 	}
-	
+
 	vmManagerURL := os.Getenv("VM_MANAGER_URL")
 	if vmManagerURL == "" {
 		vmManagerURL = "http://vm-manager:8083"
 	}
-	
+
+	// VM_MANAGER_URLS lists every VM manager host; it defaults to VM_MANAGER_URL
+	vmManagerURLs := parseURLList(os.Getenv("VM_MANAGER_URLS"))
+	if len(vmManagerURLs) == 0 {
+		vmManagerURLs = []string{vmManagerURL}
+	}
+
 	commandExecutorURL := os.Getenv("COMMAND_EXECUTOR_URL")
 	if commandExecutorURL == "" {
 		commandExecutorURL = "http://command-executor:8084"
 	}
-	
+
 	logger.Infof("Agent System URL: %s", agentSystemURL)
-	logger.Infof("VM Manager URL: %s", vmManagerURL)
+	logger.Infof("VM Manager URLs: %s", strings.Join(vmManagerURLs, ", "))
 	logger.Infof("Command Executor URL: %s", commandExecutorURL)
 
-	router := mux.NewRouter()
-	router.HandleFunc("/tasks", createTaskHandler).Methods("POST")
-	router.HandleFunc("/tasks/{id}", getTaskHandler).Methods("GET")
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	scheduler = newVMScheduler(vmManagerURLs)
+
+	if value := os.Getenv("PLACEMENT_MAX_WAIT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			placementMaxWait = d
+		} else {
+			logger.Warnf("Ignoring invalid PLACEMENT_MAX_WAIT %q", value)
+		}
+	}
+
+	if value := os.Getenv("AFFINITY_TTL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			affinityTTL = d
+		} else {
+			logger.Warnf("Ignoring invalid AFFINITY_TTL %q", value)
+		}
+	}
+
+	if value := os.Getenv("TASK_DEFAULT_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			defaultTaskTimeout = d
+		} else {
+			logger.Warnf("Ignoring invalid TASK_DEFAULT_TIMEOUT %q", value)
+		}
+	}
+	maxBodyBytes = maxBodyBytesFromEnv()
+
+	// DEFAULT_API_VERSION pins the response shape for clients that don't ask
+	if value := os.Getenv("DEFAULT_API_VERSION"); value != "" {
+		if n, err := strconv.Atoi(strings.TrimPrefix(value, "v")); err == nil && n >= minAPIVersion && n <= latestAPIVersion {
+			defaultAPIVersion = n
+		} else {
+			logger.Warnf("Ignoring invalid DEFAULT_API_VERSION %q", value)
+		}
+	}
+
+	replicaID = os.Getenv("ORCHESTRATOR_ID")
+	if replicaID == "" {
+		replicaID, _ = os.Hostname()
+	}
+	logger.Infof("Replica ID: %s", replicaID)
+
+	var err error
+	store, err = newStoreFromEnv()
+	if err != nil {
+		logger.Fatalf("Failed to initialize task store: %v", err)
+	}
+	if err := loadTasks(); err != nil {
+		logger.Errorf("Failed to load tasks from store: %v", err)
+	}
+
+	maxDeadLetters := 1000
+	if value := os.Getenv("DEADLETTER_MAX_TASKS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			maxDeadLetters = n
+		} else {
+			logger.Warnf("Ignoring invalid DEADLETTER_MAX_TASKS %q", value)
+		}
+	}
+	deadLetters = newDeadLetterQueue(maxDeadLetters)
+	loadDeadLetters()
+
+	artifactDir := os.Getenv("ARTIFACT_DIR")
+	if artifactDir == "" {
+		artifactDir = "/var/lib/orchestrator/artifacts"
+	}
+	maxArtifactBytes := int64(100 << 20)
+	if value := os.Getenv("ARTIFACT_MAX_TASK_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			maxArtifactBytes = n
+		} else {
+			logger.Warnf("Ignoring invalid ARTIFACT_MAX_TASK_BYTES %q", value)
+		}
+	}
+	artifacts, err = newArtifactStore(artifactDir, maxArtifactBytes)
+	if err != nil {
+		logger.Fatalf("Failed to initialize artifact store: %v", err)
+	}
+
+	if n := recoverTasks(); n > 0 {
+		logger.Infof("Re-dispatched %d unfinished tasks from before the restart", n)
+	}
 
 	port := "8081"
 	logger.Infof("Orchestrator listening on port %s", port)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), router)
+	err = http.ListenAndServe(fmt.Sprintf(":%s", port), stripTrailingSlash(newRouter()))
 	if err != nil {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// newRouter registers the orchestrator's routes
+func newRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/tasks", createTaskHandler).Methods("POST")
+	router.HandleFunc("/tasks/deadletter", listDeadLettersHandler).Methods("GET")
+	router.HandleFunc("/tasks/{id}", getTaskHandler).Methods("GET")
+	router.HandleFunc("/tasks/{id}/replay", replayTaskHandler).Methods("POST")
+	router.HandleFunc("/tasks/{id}/artifacts", listArtifactsHandler).Methods("GET")
+	router.HandleFunc("/tasks/{id}/artifacts/{name}", uploadArtifactHandler).Methods("PUT")
+	router.HandleFunc("/tasks/{id}/artifacts/{name}", downloadArtifactHandler).Methods("GET")
+	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	router.HandleFunc("/version", versionHandler).Methods("GET")
+	router.HandleFunc("/admin/pause", pauseHandler).Methods("POST")
+	router.HandleFunc("/admin/resume", resumeHandler).Methods("POST")
+	router.Use(apiVersionMiddleware)
+	configureRouter(router)
+	return router
+}
+
 func createTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse request
 	var requestData struct {
 		Instruction string      `json:"instruction"`
 		Parameters  interface{} `json:"parameters,omitempty"`
+
+		AffinityGroup     string `json:"affinity_group,omitempty"`
+		AntiAffinityGroup string `json:"anti_affinity_group,omitempty"`
+
+		// Timeout overrides TASK_DEFAULT_TIMEOUT, e.g. "10m"
+		Timeout string `json:"timeout,omitempty"`
+
+		DependsOn []string `json:"depends_on,omitempty"`
+		Runtime   string   `json:"runtime,omitempty"`
 	}
-	
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		logger.Errorf("Failed to decode request: %v", err)
 		http.Error(w, "Failed to decode request", http.StatusBadRequest)
 		return
 	}
-	
+
+	runtime := strings.ToLower(strings.TrimSpace(requestData.Runtime))
+	if runtime == "" {
+		runtime = defaultRuntime
+	}
+	if !knownRuntimes[runtime] {
+		http.Error(w, fmt.Sprintf("Unknown runtime %q", requestData.Runtime), http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultTaskTimeout
+	if requestData.Timeout != "" {
+		timeout, err = time.ParseDuration(requestData.Timeout)
+		if err != nil || timeout <= 0 {
+			http.Error(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Generate a task ID
-	taskID := fmt.Sprintf("task-%d", time.Now().UnixNano())
-	
+	taskID := newTaskID()
+
+	if err := validateDependencies(taskID, requestData.DependsOn); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Create a new task
 	now := time.Now()
+	deadline := now.Add(timeout)
 	task := &Task{
 		ID:          taskID,
 		Instruction: requestData.Instruction,
 		Status:      TaskStatusPending,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		DeadlineAt:  &deadline,
+		Owner:       replicaID,
+
+		AffinityGroup:     requestData.AffinityGroup,
+		AntiAffinityGroup: requestData.AntiAffinityGroup,
+		DependsOn:         requestData.DependsOn,
+		Runtime:           runtime,
 	}
-	
+
 	// Store the task
-	TaskStore[taskID] = task
-	
-	// Start processing the task asynchronously
-	go processTask(task)
-	
+	saveTask(task)
+
+	// Start processing the task asynchronously, unless dispatching is paused
+	// or it is waiting on dependencies
+	dispatch.Submit(task)
+
 	// Return the task ID
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+	writeResponse(w, r, map[string]string{"task_id": taskID})
 }
 
 func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// Get task ID from URL
 	vars := mux.Vars(r)
 	taskID := vars["id"]
-	
+
 	// Get the task from the store
-	task, ok := TaskStore[taskID]
+	task, ok := getTask(taskID)
 	if !ok {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
-	
+	if task.Status == TaskStatusProcessing && task.StartedAt != nil {
+		task.ProcessingDuration = time.Since(*task.StartedAt).Seconds()
+	}
+
 	// Return the task
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(task)
+	writeResponse(w, r, task)
 }
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	paused, queued := dispatch.State()
+	writeResponse(w, r, map[string]interface{}{
+		"status":     "healthy",
+		"version":    version,
+		"git_commit": gitCommit,
+		"paused":     paused,
+		"queued":     queued,
+	})
+}
+
+func pauseHandler(w http.ResponseWriter, r *http.Request) {
+	dispatch.Pause()
+	logger.Warn("Task dispatching paused")
+
+	_, queued := dispatch.State()
+	writeResponse(w, r, map[string]interface{}{"paused": true, "queued": queued})
+}
+
+func resumeHandler(w http.ResponseWriter, r *http.Request) {
+	released := dispatch.Resume()
+	logger.Infof("Task dispatching resumed, released %d queued tasks", released)
+
+	writeResponse(w, r, map[string]interface{}{"paused": false, "released": released})
 }
 
 func processTask(task *Task) {
+	// Release or fail any tasks depending on this one once it finishes
+	defer dispatch.Finished(task.ID)
+
 	// Update task status
-	task.Status = TaskStatusProcessing
-	task.UpdatedAt = time.Now()
-	
-	// Simulate task processing
-	time.Sleep(2 * time.Second)
-	
-	// Update task with result
-	task.Status = TaskStatusCompleted
-	task.Result = map[string]interface{}{
-		"message": fmt.Sprintf("Processed instruction: %s", task.Instruction),
+	updateTask(task, func(t *Task) {
+		t.Status = TaskStatusProcessing
+	})
+
+	// Turn the instruction into a plan, failing fast if it isn't understood
+	plan, err := parser.Parse(task.Instruction)
+	if err != nil {
+		failTask(task, err)
+		return
+	}
+	updateTask(task, func(t *Task) {
+		t.Plan = plan
+	})
+
+	if err := checkDeadline(task); err != nil {
+		failTask(task, err)
+		return
+	}
+
+	// Pick the VM manager host for this task
+	host, err := placeTask(task)
+	if err != nil {
+		failTask(task, err)
+		return
+	}
+	logger.Infof("Task %s placed on VM manager %s", task.ID, host)
+
+	if err := savePlanArtifact(task.ID, plan); err != nil {
+		logger.Warnf("Failed to store plan artifact for task %s: %v", task.ID, err)
+	}
+
+	// The plan is not executed. The command executor runs commands inside its
+	// own shared container and cannot reach the placed VM, and VMs don't
+	// expose connection details yet, so running the plan there would change
+	// shared infrastructure instead of the task's VM.
+	updateTask(task, func(t *Task) {
+		t.Status = TaskStatusPlanned
+		t.Result = map[string]interface{}{
+			"message":  fmt.Sprintf("Processed instruction: %s", t.Instruction),
+			"commands": plan.Commands(),
+		}
+	})
+
+	logger.Infof("Task %s planned", task.ID)
+}
+
+// placeTask places the task on a VM manager. While every eligible manager is
+// shedding load the task goes back to queued and placement is retried after
+// the managers' Retry-After, until placementMaxWait or the task deadline.
+func placeTask(task *Task) (string, error) {
+	giveUpAt := time.Now().Add(placementMaxWait)
+	for {
+		host, err := scheduler.PlaceTask(task)
+		var busy *busyError
+		if !errors.As(err, &busy) {
+			return host, err
+		}
+
+		delay := busy.retryAfter
+		if delay <= 0 {
+			delay = busyRetryDelay
+		}
+		if time.Now().Add(delay).After(giveUpAt) {
+			return "", fmt.Errorf("%v; gave up after waiting up to %s", err, placementMaxWait)
+		}
+
+		logger.Warnf("Task %s waiting %s for a VM manager: %v", task.ID, delay, err)
+		updateTask(task, func(t *Task) {
+			t.Status = TaskStatusQueued
+		})
+		time.Sleep(delay)
+
+		if err := checkDeadline(task); err != nil {
+			return "", err
+		}
+		updateTask(task, func(t *Task) {
+			t.Status = TaskStatusProcessing
+		})
+	}
+}
+
+// checkDeadline returns ErrDeadlineExceeded once the task is past DeadlineAt.
+// Tasks persisted before deadlines existed have none.
+func checkDeadline(task *Task) error {
+	taskMutex.RLock()
+	deadline := task.DeadlineAt
+	taskMutex.RUnlock()
+
+	if deadline != nil && time.Now().After(*deadline) {
+		return fmt.Errorf("%w (deadline was %s)", ErrDeadlineExceeded, deadline.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// failTask marks the task failed with err and moves it to the dead-letter queue
+func failTask(task *Task, err error) {
+	logger.Errorf("Task %s failed: %v", task.ID, err)
+	updateTask(task, func(t *Task) {
+		t.Status = TaskStatusFailed
+		t.Error = err.Error()
+	})
+	deadLetters.Add(task.ID)
+}
+
+func newTaskID() string {
+	return fmt.Sprintf("task-%d", time.Now().UnixNano())
+}
+
+// maxBodyBytesFromEnv returns the request body limit from MAX_REQUEST_BODY_BYTES
+func maxBodyBytesFromEnv() int64 {
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && n > 0 {
+			return n
+		}
+		logger.Warnf("Ignoring invalid MAX_REQUEST_BODY_BYTES %q", value)
+	}
+	return 1 << 20
+}
+
+// loadTasks warms the cache from the persistent store
+func loadTasks() error {
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return err
+	}
+
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+	for _, task := range tasks {
+		TaskStore[task.ID] = task
+	}
+	logger.Infof("Loaded %d tasks from store", len(tasks))
+	return nil
+}
+
+// getTask returns a copy of the task. Finished tasks are served from the
+// cache; unfinished ones are read through to the store, since another replica
+// may be the one updating them, and the cache is refreshed when the stored
+// copy is newer.
+func getTask(id string) (*Task, bool) {
+	taskMutex.RLock()
+	task, ok := TaskStore[id]
+	if ok && isFinished(task.Status) {
+		snapshot := *task
+		taskMutex.RUnlock()
+		return &snapshot, true
+	}
+	taskMutex.RUnlock()
+
+	loaded, err := store.LoadTask(id)
+	if err != nil {
+		if !errors.Is(err, ErrTaskNotFound) {
+			logger.Errorf("Failed to load task %s: %v", id, err)
+		}
+		if !ok {
+			return nil, false
+		}
+		// Serve the cached copy while the store is unavailable
+		loaded = nil
 	}
+
+	taskMutex.Lock()
+	if cached, exists := TaskStore[id]; exists {
+		// Local updates stamp UpdatedAt before writing through, so an older
+		// or equal stored copy never overwrites them
+		if loaded != nil && loaded.UpdatedAt.After(cached.UpdatedAt) {
+			*cached = *loaded
+		}
+		task = cached
+	} else if loaded != nil {
+		TaskStore[id] = loaded
+		task = loaded
+	} else {
+		taskMutex.Unlock()
+		return nil, false
+	}
+	snapshot := *task
+	taskMutex.Unlock()
+	return &snapshot, true
+}
+
+// isFinished reports whether a task status is terminal
+func isFinished(status string) bool {
+	return status == TaskStatusCompleted || status == TaskStatusPlanned || status == TaskStatusFailed
+}
+
+// recoverTasks re-dispatches unfinished tasks that this replica owned before a
+// restart, oldest first. Tasks owned by other replicas are left to them.
+func recoverTasks() int {
+	var orphaned []*Task
+	taskMutex.Lock()
+	for _, task := range TaskStore {
+		if isFinished(task.Status) || (task.Owner != "" && task.Owner != replicaID) {
+			continue
+		}
+		orphaned = append(orphaned, task)
+	}
+	taskMutex.Unlock()
+
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].CreatedAt.Before(orphaned[j].CreatedAt) })
+	for _, task := range orphaned {
+		updateTask(task, func(t *Task) {
+			t.Status = TaskStatusPending
+			t.Owner = replicaID
+		})
+		dispatch.Submit(task)
+	}
+	return len(orphaned)
+}
+
+// saveTask caches the task and writes it through to the store
+func saveTask(task *Task) {
+	taskMutex.Lock()
+	TaskStore[task.ID] = task
+	snapshot := *task
+	taskMutex.Unlock()
+
+	if err := store.SaveTask(&snapshot); err != nil {
+		logger.Errorf("Failed to persist task %s: %v", task.ID, err)
+	}
+}
+
+// updateTask applies fn to the task under the cache lock and persists it. It
+// also tracks the processing window: StartedAt is stamped on entering
+// processing and ProcessingDuration is fixed once the task finishes.
+func updateTask(task *Task, fn func(t *Task)) {
+	taskMutex.Lock()
+	fn(task)
 	task.UpdatedAt = time.Now()
-	
-	logger.Infof("Task %s completed", task.ID)
+	switch task.Status {
+	case TaskStatusProcessing:
+		if task.StartedAt == nil {
+			started := task.UpdatedAt
+			task.StartedAt = &started
+		}
+	case TaskStatusCompleted, TaskStatusPlanned, TaskStatusFailed:
+		if task.StartedAt != nil && task.ProcessingDuration == 0 {
+			task.ProcessingDuration = task.UpdatedAt.Sub(*task.StartedAt).Seconds()
+		}
+	}
+	snapshot := *task
+	taskMutex.Unlock()
+
+	if err := store.SaveTask(&snapshot); err != nil {
+		logger.Errorf("Failed to persist task %s: %v", task.ID, err)
+	}
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// setupTest resets the package state shared by the handlers and processTask
+// and places tasks on the given VM managers
+func setupTest(t *testing.T, managerURLs ...string) {
+	t.Helper()
+
+	taskMutex.Lock()
+	TaskStore = make(map[string]*Task)
+	taskMutex.Unlock()
+
+	var err error
+	artifacts, err = newArtifactStore(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = newMemoryStore()
+	scheduler = newVMScheduler(managerURLs)
+	dispatch = &dispatcher{}
+	// Let background processTask calls finish before the next test resets
+	// the package state underneath them
+	t.Cleanup(dispatch.Wait)
+	deadLetters = newDeadLetterQueue(100)
+	maxBodyBytes = 1 << 20
+	replicaID = "test-replica"
+	defaultTaskTimeout = 30 * time.Minute
+	placementMaxWait = 5 * time.Minute
+	affinityTTL = time.Hour
+}
+
+// newStubManager starts a VM manager answering /health with the given
+// runtimes and /vms with vmCount VMs
+func newStubManager(t *testing.T, runtimes []string, vmCount int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "healthy", "runtimes": runtimes})
+		case "/vms":
+			vms := make([]map[string]string, vmCount)
+			for i := range vms {
+				vms[i] = map[string]string{"id": "vm"}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"vms": vms})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// doRequest sends a request through the orchestrator router
+func doRequest(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	rec := httptest.NewRecorder()
+	stripTrailingSlash(newRouter()).ServeHTTP(rec, httptest.NewRequest(method, path, reader))
+	return rec
+}
+
+// submitTask creates a task through the API and returns its ID
+func submitTask(t *testing.T, request map[string]interface{}) string {
+	t.Helper()
+	rec := doRequest(t, http.MethodPost, "/tasks", request)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /tasks returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.TaskID
+}
+
+// waitForStatus polls the task until it reaches status
+func waitForStatus(t *testing.T, id, status string) *Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, ok := getTask(id)
+		if ok && task.Status == status {
+			return task
+		}
+		if time.Now().After(deadline) {
+			if ok {
+				t.Fatalf("task %s is %s (%s), want %s", id, task.Status, task.Error, status)
+			}
+			t.Fatalf("task %s not found", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessTaskPlansWithoutExecuting(t *testing.T) {
+	manager := newStubManager(t, nil, 0)
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx and start it"})
+	task := waitForStatus(t, id, TaskStatusPlanned)
+
+	if task.VMManager != manager.URL {
+		t.Errorf("VMManager = %q, want %q", task.VMManager, manager.URL)
+	}
+	result, ok := task.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Result = %#v", task.Result)
+	}
+	want := []string{"zypper --non-interactive install nginx", "systemctl start nginx"}
+	commands, _ := result["commands"].([]string)
+	if len(commands) != len(want) {
+		t.Fatalf("commands = %q, want %q", commands, want)
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Errorf("commands[%d] = %q, want %q", i, commands[i], want[i])
+		}
+	}
+}
+
+func TestCreateTaskBodyLimit(t *testing.T) {
+	setupTest(t)
+	maxBodyBytes = 64
+
+	rec := doRequest(t, http.MethodPost, "/tasks", map[string]interface{}{"instruction": strings.Repeat("a", 100)})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+	taskMutex.RLock()
+	saved := len(TaskStore)
+	taskMutex.RUnlock()
+	if saved != 0 {
+		t.Errorf("%d tasks saved for a rejected request", saved)
+	}
+}
+
+func TestCompletedTaskReportsProcessingDuration(t *testing.T) {
+	// A manager that takes a moment to report its VMs, so placement is measurable
+	manager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/vms" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "healthy", "vms": []interface{}{}})
+	}))
+	t.Cleanup(manager.Close)
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx", "timeout": "1m"})
+	waitForStatus(t, id, TaskStatusPlanned)
+
+	rec := doRequest(t, http.MethodGet, "/tasks/"+id, nil)
+	var task Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.ProcessingDuration < 0.05 || task.ProcessingDuration > 5 {
+		t.Errorf("processing_duration_seconds = %v, want between 0.05 and 5", task.ProcessingDuration)
+	}
+	if task.StartedAt == nil || task.StartedAt.Before(task.CreatedAt) || task.StartedAt.After(task.UpdatedAt) {
+		t.Errorf("started_at = %v, want between %v and %v", task.StartedAt, task.CreatedAt, task.UpdatedAt)
+	}
+	if task.DeadlineAt == nil || task.DeadlineAt.Sub(task.CreatedAt) != time.Minute {
+		t.Errorf("deadline_at = %v, want created_at + 1m", task.DeadlineAt)
+	}
+}
+
+func TestTaskWithoutDeadlineOmitsIt(t *testing.T) {
+	data, err := json.Marshal(&Task{ID: "task-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "deadline_at") {
+		t.Errorf("task without a deadline encodes as %s", data)
+	}
+}
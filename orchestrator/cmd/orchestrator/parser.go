@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnrecognizedInstruction is returned when no rule matches an instruction
+var ErrUnrecognizedInstruction = errors.New("unrecognized instruction")
+
+// Step is a single action of a plan together with the command that performs it
+type Step struct {
+	Action  string   `json:"action"`
+	Targets []string `json:"targets,omitempty"`
+	Command string   `json:"command"`
+}
+
+// Plan is the structured form of a task instruction
+type Plan struct {
+	Steps []Step `json:"steps"`
+}
+
+// Commands returns the shell commands of the plan in order
+func (p *Plan) Commands() []string {
+	commands := make([]string, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		commands = append(commands, step.Command)
+	}
+	return commands
+}
+
+// InstructionParser turns a natural-language instruction into a Plan
+type InstructionParser interface {
+	Parse(instruction string) (*Plan, error)
+}
+
+// parseRule maps a clause prefix to an action
+type parseRule struct {
+	action  string
+	pattern *regexp.Regexp
+	// command renders the shell command for the clause targets
+	command func(targets []string) string
+	// needsTargets is false for actions that operate on the whole system
+	needsTargets bool
+}
+
+// keywordParser is the default parser. It splits an instruction into clauses
+// ("install nginx and configure it to start at boot") and matches each
+// clause against a fixed list of openSUSE administration patterns.
+type keywordParser struct {
+	rules []parseRule
+}
+
+var (
+	clauseSeparator = regexp.MustCompile(`(?i)\s*(?:,|;|\band then\b|\bthen\b|\band\b)\s*`)
+	targetPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+@-]*$`)
+	fillerWords     = map[string]bool{
+		"the": true, "a": true, "an": true, "package": true, "packages": true,
+		"service": true, "services": true, "new": true, "also": true,
+	}
+)
+
+func newKeywordParser() *keywordParser {
+	zypper := func(verb string) func([]string) string {
+		return func(targets []string) string {
+			return "zypper --non-interactive " + verb + " " + strings.Join(targets, " ")
+		}
+	}
+	systemctl := func(verb string) func([]string) string {
+		return func(targets []string) string {
+			return "systemctl " + verb + " " + strings.Join(targets, " ")
+		}
+	}
+
+	return &keywordParser{rules: []parseRule{
+		{action: "update", pattern: regexp.MustCompile(`(?i)^(?:update|upgrade)(?:\s+(?:the\s+)?(?:system|all\s+packages|packages|everything))?$`),
+			command: func([]string) string {
+				return "zypper --non-interactive refresh && zypper --non-interactive dist-upgrade"
+			}},
+		{action: "update", pattern: regexp.MustCompile(`(?i)^(?:update|upgrade)\s+(.+)$`),
+			command: zypper("update"), needsTargets: true},
+		{action: "enable", pattern: regexp.MustCompile(`(?i)^(?:configure|set|make|set\s+up)\s+(.+?)\s+to\s+start\s+(?:at|on)\s+boot$`),
+			command: systemctl("enable"), needsTargets: true},
+		{action: "enable", pattern: regexp.MustCompile(`(?i)^start\s+(.+?)\s+(?:at|on)\s+boot$`),
+			command: systemctl("enable"), needsTargets: true},
+		{action: "create_user", pattern: regexp.MustCompile(`(?i)^(?:create|add)\s+(?:a\s+)?user\s+(\S+)$`),
+			command: func(targets []string) string {
+				return "useradd -m " + strings.Join(targets, " ")
+			}, needsTargets: true},
+		{action: "install", pattern: regexp.MustCompile(`(?i)^(?:install|add)\s+(.+)$`),
+			command: zypper("install"), needsTargets: true},
+		{action: "remove", pattern: regexp.MustCompile(`(?i)^(?:remove|uninstall|delete)\s+(.+)$`),
+			command: zypper("remove"), needsTargets: true},
+		{action: "enable", pattern: regexp.MustCompile(`(?i)^enable\s+(.+)$`),
+			command: systemctl("enable"), needsTargets: true},
+		{action: "disable", pattern: regexp.MustCompile(`(?i)^disable\s+(.+)$`),
+			command: systemctl("disable"), needsTargets: true},
+		{action: "start", pattern: regexp.MustCompile(`(?i)^start\s+(.+)$`),
+			command: systemctl("start"), needsTargets: true},
+		{action: "stop", pattern: regexp.MustCompile(`(?i)^stop\s+(.+)$`),
+			command: systemctl("stop"), needsTargets: true},
+		{action: "restart", pattern: regexp.MustCompile(`(?i)^restart\s+(.+)$`),
+			command: systemctl("restart"), needsTargets: true},
+	}}
+}
+
+// Parse implements InstructionParser
+func (p *keywordParser) Parse(instruction string) (*Plan, error) {
+	text := strings.TrimRight(strings.TrimSpace(instruction), ".!")
+	if text == "" {
+		return nil, fmt.Errorf("%w: empty instruction", ErrUnrecognizedInstruction)
+	}
+
+	plan := &Plan{}
+	var lastRule *parseRule
+	var lastTargets []string
+	for _, clause := range clauseSeparator.Split(text, -1) {
+		if clause == "" {
+			continue
+		}
+
+		rule, match := p.match(clause)
+		if rule == nil {
+			// A bare object ("install nginx and curl") extends the previous step
+			if lastRule == nil || !lastRule.needsTargets || lastRule.action == "create_user" {
+				return nil, fmt.Errorf("%w: %q", ErrUnrecognizedInstruction, clause)
+			}
+			targets, err := parseTargets(clause, nil)
+			if err != nil {
+				return nil, err
+			}
+			last := &plan.Steps[len(plan.Steps)-1]
+			last.Targets = append(last.Targets, targets...)
+			last.Command = lastRule.command(last.Targets)
+			lastTargets = last.Targets
+			continue
+		}
+
+		step := Step{Action: rule.action}
+		if rule.needsTargets {
+			targets, err := parseTargets(match, lastTargets)
+			if err != nil {
+				return nil, err
+			}
+			step.Targets = targets
+			lastTargets = targets
+		}
+		step.Command = rule.command(step.Targets)
+		plan.Steps = append(plan.Steps, step)
+		lastRule = rule
+	}
+
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnrecognizedInstruction, instruction)
+	}
+	return plan, nil
+}
+
+// match returns the first rule matching the clause and its captured targets
+func (p *keywordParser) match(clause string) (*parseRule, string) {
+	for i := range p.rules {
+		m := p.rules[i].pattern.FindStringSubmatch(clause)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 {
+			return &p.rules[i], m[1]
+		}
+		return &p.rules[i], ""
+	}
+	return nil, ""
+}
+
+// parseTargets splits the object of a clause into package/unit names. "it"
+// and "them" refer back to the previous clause's targets. Names are
+// restricted to a safe charset because plans end up in shell commands.
+func parseTargets(text string, previous []string) ([]string, error) {
+	var targets []string
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(word)
+		if fillerWords[lower] {
+			continue
+		}
+		if lower == "it" || lower == "them" {
+			if len(previous) == 0 {
+				return nil, fmt.Errorf("%w: %q has nothing to refer to", ErrUnrecognizedInstruction, word)
+			}
+			targets = append(targets, previous...)
+			continue
+		}
+		if !targetPattern.MatchString(word) {
+			return nil, fmt.Errorf("%w: invalid name %q", ErrUnrecognizedInstruction, word)
+		}
+		targets = append(targets, word)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: missing target in %q", ErrUnrecognizedInstruction, text)
+	}
+	return targets, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeywordParserParse(t *testing.T) {
+	tests := []struct {
+		instruction string
+		want        []string
+	}{
+		{"install nginx", []string{"zypper --non-interactive install nginx"}},
+		{"Install nginx and curl.", []string{"zypper --non-interactive install nginx curl"}},
+		{"install the nginx package", []string{"zypper --non-interactive install nginx"}},
+		{"install nginx and configure it to start at boot", []string{
+			"zypper --non-interactive install nginx",
+			"systemctl enable nginx",
+		}},
+		{"install nginx, start it and enable it", []string{
+			"zypper --non-interactive install nginx",
+			"systemctl start nginx",
+			"systemctl enable nginx",
+		}},
+		{"install nginx and curl, then restart them", []string{
+			"zypper --non-interactive install nginx curl",
+			"systemctl restart nginx curl",
+		}},
+		{"remove apache2 then restart nginx", []string{
+			"zypper --non-interactive remove apache2",
+			"systemctl restart nginx",
+		}},
+		{"update the system", []string{"zypper --non-interactive refresh && zypper --non-interactive dist-upgrade"}},
+		{"update nginx", []string{"zypper --non-interactive update nginx"}},
+		{"create a user alice", []string{"useradd -m alice"}},
+		{"stop the nginx service", []string{"systemctl stop nginx"}},
+		{"start sshd on boot", []string{"systemctl enable sshd"}},
+	}
+
+	p := newKeywordParser()
+	for _, tt := range tests {
+		plan, err := p.Parse(tt.instruction)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.instruction, err)
+			continue
+		}
+		if got := plan.Commands(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) commands = %q, want %q", tt.instruction, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordParserRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		instruction string
+	}{
+		{"empty", "  "},
+		{"unrecognized", "make me a sandwich"},
+		{"shell injection", "install nginx; rm -rf /"},
+		{"command substitution", "install $(reboot)"},
+		{"shell operator", "install nginx && reboot"},
+		{"dangling pronoun", "start it"},
+		{"missing target", "install the"},
+		{"object after create user", "create user alice and bob"},
+		{"object after system update", "update the system and curl"},
+	}
+
+	p := newKeywordParser()
+	for _, tt := range tests {
+		plan, err := p.Parse(tt.instruction)
+		if !errors.Is(err, ErrUnrecognizedInstruction) {
+			t.Errorf("%s: Parse(%q) = %v, %v; want ErrUnrecognizedInstruction", tt.name, tt.instruction, plan, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeResponse encodes v as YAML when the client asks for it and as JSON
+// otherwise. YAML output goes through JSON first so both formats share the
+// same field names and omitempty behaviour.
+func writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	writeStatusResponse(w, r, http.StatusOK, v)
+}
+
+// writeStatusResponse is writeResponse with an explicit status code. Payloads
+// are rendered in the API version resolved by apiVersionMiddleware.
+func writeStatusResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	version := apiVersion(r)
+	w.Header().Set("X-API-Version", strconv.Itoa(version))
+	v = applyAPIVersion(v, version)
+
+	if !wantsYAML(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(status)
+	enc := yaml.NewEncoder(w)
+	enc.Encode(generic)
+	enc.Close()
+}
+
+// wantsYAML reports whether the Accept header asks for a YAML media type
+func wantsYAML(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGetTaskYAML(t *testing.T) {
+	setupTest(t)
+	now := time.Now()
+	saveTask(&Task{ID: "task-1", Instruction: "list files", Status: TaskStatusCompleted, Owner: replicaID, CreatedAt: now, UpdatedAt: now})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/task-1", nil)
+	req.Header.Set("Accept", "application/yaml")
+	rec := httptest.NewRecorder()
+	stripTrailingSlash(newRouter()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("invalid YAML: %v\n%s", err, rec.Body.String())
+	}
+	if fields["id"] != "task-1" || fields["status"] != TaskStatusCompleted || fields["instruction"] != "list files" {
+		t.Errorf("fields = %v", fields)
+	}
+}
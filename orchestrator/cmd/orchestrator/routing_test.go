@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	setupTest(t)
+
+	tests := []struct {
+		method, path, allow string
+	}{
+		{http.MethodGet, "/tasks", "POST"},
+		{http.MethodDelete, "/tasks/task-1", "GET"},
+		{http.MethodDelete, "/tasks/task-1/artifacts/out.log", "GET, PUT"},
+		{http.MethodGet, "/admin/pause", "POST"},
+	}
+	for _, tt := range tests {
+		rec := doRequest(t, tt.method, tt.path, nil)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want 405", tt.method, tt.path, rec.Code)
+			continue
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "method not allowed" {
+			t.Errorf("%s %s: body = %s", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestNotFoundIsJSON(t *testing.T) {
+	setupTest(t)
+
+	rec := doRequest(t, http.MethodGet, "/nope", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["path"] != "/nope" {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	setupTest(t)
+
+	for _, path := range []string{"/health/", "/tasks/deadletter/"} {
+		if rec := doRequest(t, http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", path, rec.Code)
+		}
+	}
+}
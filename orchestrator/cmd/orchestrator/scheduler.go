@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrManagersBusy is returned when no manager can take a task right now but at
+// least one is only shedding load (429/503) rather than down
+var ErrManagersBusy = errors.New("VM managers are at capacity")
+
+// busyError carries the shortest Retry-After reported by the busy managers
+type busyError struct {
+	retryAfter time.Duration
+	detail     string
+}
+
+func (e *busyError) Error() string {
+	return fmt.Sprintf("%v (%s)", ErrManagersBusy, e.detail)
+}
+
+func (e *busyError) Unwrap() error {
+	return ErrManagersBusy
+}
+
+// Isolation runtimes a task can ask for. Managers that don't advertise any
+// runtime in their health payload are assumed to provide plain KVM.
+const defaultRuntime = "kvm"
+
+var knownRuntimes = map[string]bool{
+	"kvm":    true,
+	"kata":   true,
+	"gvisor": true,
+}
+
+// hostStatus is the result of probing a single VM manager
+type hostStatus struct {
+	URL      string
+	Healthy  bool
+	VMCount  int
+	Runtimes []string
+	Err      error
+
+	// RuntimesKnown is false until the manager has answered a health check,
+	// so a busy manager that was never seen healthy may provide any runtime
+	RuntimesKnown bool
+
+	// Busy is set when the manager answered 429 or 503, optionally with a
+	// Retry-After hint
+	Busy       bool
+	RetryAfter time.Duration
+}
+
+// supportsRuntime reports whether the manager advertises the runtime
+func (st hostStatus) supportsRuntime(runtime string) bool {
+	if len(st.Runtimes) == 0 {
+		return runtime == defaultRuntime
+	}
+	for _, rt := range st.Runtimes {
+		if rt == runtime {
+			return true
+		}
+	}
+	return false
+}
+
+// placement carries the routing constraints for a single task
+type placement struct {
+	prefer  map[string]bool // hosts running VMs of the task's affinity group
+	avoid   map[string]bool // hosts running VMs of the task's anti-affinity group
+	runtime string          // isolation runtime the host must provide
+}
+
+// vmScheduler picks which VM manager a task's VM should be placed on
+type vmScheduler struct {
+	urls   []string
+	client *http.Client
+
+	// placeMutex serializes placement so tasks in the same group see each
+	// other's decisions
+	placeMutex sync.Mutex
+
+	// runtimes remembers what each manager last advertised, so busy managers
+	// can still be ruled out for runtimes they don't provide
+	runtimesMutex sync.Mutex
+	runtimes      map[string][]string
+}
+
+func newVMScheduler(urls []string) *vmScheduler {
+	return &vmScheduler{
+		urls:     urls,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		runtimes: make(map[string][]string),
+	}
+}
+
+// parseURLList splits a comma-separated list of URLs, dropping blanks
+func parseURLList(value string) []string {
+	var urls []string
+	for _, u := range strings.Split(value, ",") {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// pinsHost reports whether a placed task still holds its host for affinity
+// purposes: while it is in flight and for affinityTTL after it finished.
+// Failed tasks release their host straight away.
+func pinsHost(t *Task, now time.Time) bool {
+	switch {
+	case t.VMManager == "" || t.Status == TaskStatusFailed:
+		return false
+	case !isFinished(t.Status):
+		return true
+	default:
+		return now.Sub(t.UpdatedAt) < affinityTTL
+	}
+}
+
+// PlaceTask selects a host for the task, honoring its runtime and affinity
+// hints, and records it on the task
+func (s *vmScheduler) PlaceTask(task *Task) (string, error) {
+	s.placeMutex.Lock()
+	defer s.placeMutex.Unlock()
+
+	p := placement{prefer: make(map[string]bool), avoid: make(map[string]bool)}
+
+	now := time.Now()
+	taskMutex.RLock()
+	affinity, antiAffinity := task.AffinityGroup, task.AntiAffinityGroup
+	p.runtime = task.Runtime
+	for _, t := range TaskStore {
+		if t.ID == task.ID || !pinsHost(t, now) {
+			continue
+		}
+		if affinity != "" && t.AffinityGroup == affinity {
+			p.prefer[t.VMManager] = true
+		}
+		if antiAffinity != "" && t.AntiAffinityGroup == antiAffinity {
+			p.avoid[t.VMManager] = true
+		}
+	}
+	taskMutex.RUnlock()
+
+	host, err := s.SelectHost(p)
+	if err != nil {
+		return "", err
+	}
+
+	updateTask(task, func(t *Task) {
+		t.VMManager = host
+	})
+	return host, nil
+}
+
+// SelectHost probes every VM manager and returns the least-loaded healthy one.
+// Hosts lacking p.runtime or in p.avoid are never chosen; hosts in p.prefer
+// win whenever one of them is healthy. Busy hosts only make the task wait if
+// they may provide p.runtime.
+func (s *vmScheduler) SelectHost(p placement) (string, error) {
+	statuses := s.probeAll()
+
+	var candidates, busy []hostStatus
+	var failures []string
+	for _, st := range statuses {
+		if !st.Healthy {
+			if st.Busy && p.runtime != "" && st.RuntimesKnown && !st.supportsRuntime(p.runtime) {
+				failures = append(failures, fmt.Sprintf("%s: does not provide runtime %s", st.URL, p.runtime))
+				continue
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", st.URL, st.Err))
+			if st.Busy && !p.avoid[st.URL] {
+				busy = append(busy, st)
+			}
+			continue
+		}
+		if p.runtime != "" && !st.supportsRuntime(p.runtime) {
+			failures = append(failures, fmt.Sprintf("%s: does not provide runtime %s", st.URL, p.runtime))
+			continue
+		}
+		if p.avoid[st.URL] {
+			failures = append(failures, fmt.Sprintf("%s: excluded by anti-affinity", st.URL))
+			continue
+		}
+		candidates = append(candidates, st)
+	}
+
+	if len(candidates) == 0 && len(busy) > 0 {
+		retryAfter := busy[0].RetryAfter
+		for _, st := range busy[1:] {
+			if st.RetryAfter < retryAfter {
+				retryAfter = st.RetryAfter
+			}
+		}
+		return "", &busyError{retryAfter: retryAfter, detail: strings.Join(failures, "; ")}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no eligible VM manager available (%s)", strings.Join(failures, "; "))
+	}
+
+	var preferred []hostStatus
+	for _, st := range candidates {
+		if p.prefer[st.URL] {
+			preferred = append(preferred, st)
+		}
+	}
+	if len(preferred) > 0 {
+		candidates = preferred
+	}
+
+	best := candidates[0]
+	for _, st := range candidates[1:] {
+		if st.VMCount < best.VMCount {
+			best = st
+		}
+	}
+	return best.URL, nil
+}
+
+func (s *vmScheduler) probeAll() []hostStatus {
+	statuses := make([]hostStatus, len(s.urls))
+
+	var wg sync.WaitGroup
+	for i, url := range s.urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			statuses[i] = s.probe(url)
+		}(i, url)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// probe checks a manager's health endpoint and counts its VMs as a load metric
+func (s *vmScheduler) probe(url string) hostStatus {
+	status := hostStatus{URL: url}
+
+	resp, err := s.client.Get(url + "/health")
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		status.Err = fmt.Errorf("health check returned %d", resp.StatusCode)
+		status.markBusy(resp)
+		status.Runtimes, status.RuntimesKnown = s.cachedRuntimes(url)
+		return status
+	}
+	// Older managers don't report runtimes; a body that doesn't decode is
+	// treated the same way
+	var health struct {
+		Runtimes []string `json:"runtimes"`
+	}
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	status.Runtimes = health.Runtimes
+	status.RuntimesKnown = true
+	s.cacheRuntimes(url, health.Runtimes)
+
+	resp, err = s.client.Get(url + "/vms")
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		status.Err = fmt.Errorf("listing VMs returned %d", resp.StatusCode)
+		status.markBusy(resp)
+		return status
+	}
+
+	var list struct {
+		VMs []json.RawMessage `json:"vms"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		status.Err = fmt.Errorf("failed to decode VM list: %v", err)
+		return status
+	}
+
+	status.Healthy = true
+	status.VMCount = len(list.VMs)
+	return status
+}
+
+func (s *vmScheduler) cacheRuntimes(url string, runtimes []string) {
+	s.runtimesMutex.Lock()
+	defer s.runtimesMutex.Unlock()
+	s.runtimes[url] = runtimes
+}
+
+// cachedRuntimes returns the runtimes the manager last advertised and whether
+// it has advertised any at all
+func (s *vmScheduler) cachedRuntimes(url string) ([]string, bool) {
+	s.runtimesMutex.Lock()
+	defer s.runtimesMutex.Unlock()
+	runtimes, ok := s.runtimes[url]
+	return runtimes, ok
+}
+
+// markBusy flags backpressure responses and records their Retry-After hint
+func (st *hostStatus) markBusy(resp *http.Response) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+	st.Busy = true
+	st.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date; zero means no hint
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newUnhealthyManager starts a VM manager whose health check fails
+func newUnhealthyManager(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unhealthy", status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSelectHostSkipsUnhealthyManager(t *testing.T) {
+	unhealthy := newUnhealthyManager(t, http.StatusInternalServerError)
+	healthy := newStubManager(t, nil, 5)
+
+	s := newVMScheduler([]string{unhealthy.URL, healthy.URL})
+	host, err := s.SelectHost(placement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != healthy.URL {
+		t.Errorf("SelectHost = %s, want the healthy manager %s", host, healthy.URL)
+	}
+}
+
+func TestSelectHostPrefersLeastLoaded(t *testing.T) {
+	busy := newStubManager(t, nil, 3)
+	idle := newStubManager(t, nil, 1)
+
+	s := newVMScheduler([]string{busy.URL, idle.URL})
+	host, err := s.SelectHost(placement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != idle.URL {
+		t.Errorf("SelectHost = %s, want the least-loaded manager %s", host, idle.URL)
+	}
+}
+
+func TestSelectHostReportsEveryFailure(t *testing.T) {
+	down := newUnhealthyManager(t, http.StatusInternalServerError)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	s := newVMScheduler([]string{down.URL, closed.URL})
+	_, err := s.SelectHost(placement{})
+	if err == nil {
+		t.Fatal("expected an error with no healthy manager")
+	}
+	for _, want := range []string{"no eligible VM manager", down.URL + ": health check returned 500", closed.URL + ":"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestProbeAllKeepsManagerOrder(t *testing.T) {
+	a := newStubManager(t, []string{"kvm"}, 2)
+	b := newUnhealthyManager(t, http.StatusInternalServerError)
+	c := newStubManager(t, nil, 0)
+
+	statuses := newVMScheduler([]string{a.URL, b.URL, c.URL}).probeAll()
+	if len(statuses) != 3 {
+		t.Fatalf("probeAll returned %d statuses", len(statuses))
+	}
+	if statuses[0].URL != a.URL || !statuses[0].Healthy || statuses[0].VMCount != 2 || len(statuses[0].Runtimes) != 1 {
+		t.Errorf("statuses[0] = %+v", statuses[0])
+	}
+	if statuses[1].URL != b.URL || statuses[1].Healthy || statuses[1].Err == nil {
+		t.Errorf("statuses[1] = %+v", statuses[1])
+	}
+	if statuses[2].URL != c.URL || !statuses[2].Healthy {
+		t.Errorf("statuses[2] = %+v", statuses[2])
+	}
+}
+
+func TestParseURLList(t *testing.T) {
+	got := parseURLList(" http://a:8083/ ,, http://b:8083")
+	if strings.Join(got, " ") != "http://a:8083 http://b:8083" {
+		t.Errorf("parseURLList = %q", got)
+	}
+}
+
+func TestAntiAffinitySpreadsSubmittedTasks(t *testing.T) {
+	a := newStubManager(t, nil, 0)
+	b := newStubManager(t, nil, 0)
+	setupTest(t, a.URL, b.URL)
+
+	first := submitTask(t, map[string]interface{}{"instruction": "install nginx", "anti_affinity_group": "g"})
+	firstHost := waitForStatus(t, first, TaskStatusPlanned).VMManager
+	second := submitTask(t, map[string]interface{}{"instruction": "install nginx", "anti_affinity_group": "g"})
+	secondHost := waitForStatus(t, second, TaskStatusPlanned).VMManager
+
+	if firstHost == "" || firstHost == secondHost {
+		t.Errorf("tasks in one anti-affinity group landed on %q and %q", firstHost, secondHost)
+	}
+
+	// With both hosts taken by the group a third task has nowhere to go
+	third := submitTask(t, map[string]interface{}{"instruction": "install nginx", "anti_affinity_group": "g"})
+	waitForStatus(t, third, TaskStatusFailed)
+}
+
+func TestAntiAffinityIgnoresExpiredAndFailedTasks(t *testing.T) {
+	a := newStubManager(t, nil, 0)
+	b := newStubManager(t, nil, 0)
+	setupTest(t, a.URL, b.URL)
+
+	now := time.Now()
+	expired := now.Add(-2 * affinityTTL)
+	saveTask(&Task{ID: "done-a", Status: TaskStatusPlanned, VMManager: a.URL, AntiAffinityGroup: "g", Owner: replicaID, CreatedAt: expired, UpdatedAt: expired})
+	saveTask(&Task{ID: "failed-b", Status: TaskStatusFailed, VMManager: b.URL, AntiAffinityGroup: "g", Owner: replicaID, CreatedAt: now, UpdatedAt: now})
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx", "anti_affinity_group": "g"})
+	waitForStatus(t, id, TaskStatusPlanned)
+}
+
+func TestAffinityGroupPrefersHostOfGroup(t *testing.T) {
+	// a is less loaded, but only b provides kata
+	a := newStubManager(t, nil, 0)
+	b := newStubManager(t, []string{"kvm", "kata"}, 5)
+	setupTest(t, a.URL, b.URL)
+
+	first := submitTask(t, map[string]interface{}{"instruction": "install nginx", "runtime": "kata", "affinity_group": "web"})
+	if host := waitForStatus(t, first, TaskStatusPlanned).VMManager; host != b.URL {
+		t.Fatalf("kata task placed on %s, want %s", host, b.URL)
+	}
+
+	second := submitTask(t, map[string]interface{}{"instruction": "start nginx", "affinity_group": "web"})
+	if host := waitForStatus(t, second, TaskStatusPlanned).VMManager; host != b.URL {
+		t.Errorf("task in affinity group placed on %s, want %s next to its group", host, b.URL)
+	}
+
+	other := submitTask(t, map[string]interface{}{"instruction": "start nginx"})
+	if host := waitForStatus(t, other, TaskStatusPlanned).VMManager; host != a.URL {
+		t.Errorf("ungrouped task placed on %s, want least-loaded %s", host, a.URL)
+	}
+}
+
+func TestRuntimeHintRoutesToAdvertisingManager(t *testing.T) {
+	// The KVM-only manager is less loaded, so only the runtime hint steers
+	// gVisor tasks away from it
+	kvm := newStubManager(t, nil, 0)
+	gvisor := newStubManager(t, []string{"kvm", "gvisor"}, 3)
+	setupTest(t, kvm.URL, gvisor.URL)
+
+	tests := []struct {
+		runtime string
+		want    string
+	}{
+		{"gvisor", gvisor.URL},
+		{"GVisor", gvisor.URL},
+		{"kvm", kvm.URL},
+		{"", kvm.URL},
+	}
+	for _, tt := range tests {
+		id := submitTask(t, map[string]interface{}{"instruction": "install nginx", "runtime": tt.runtime})
+		task := waitForStatus(t, id, TaskStatusPlanned)
+		if task.VMManager != tt.want {
+			t.Errorf("runtime %q placed on %s, want %s", tt.runtime, task.VMManager, tt.want)
+		}
+	}
+}
+
+func TestRuntimeWithoutManagerFails(t *testing.T) {
+	manager := newStubManager(t, []string{"kvm", "gvisor"}, 0)
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx", "runtime": "kata"})
+	task := waitForStatus(t, id, TaskStatusFailed)
+	if !strings.Contains(task.Error, "does not provide runtime kata") {
+		t.Errorf("error = %q", task.Error)
+	}
+}
+
+func TestUnknownRuntimeIsRejected(t *testing.T) {
+	setupTest(t)
+
+	rec := doRequest(t, http.MethodPost, "/tasks", map[string]interface{}{"instruction": "install nginx", "runtime": "firecracker"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", rec.Code)
+	}
+	taskMutex.RLock()
+	saved := len(TaskStore)
+	taskMutex.RUnlock()
+	if saved != 0 {
+		t.Errorf("%d tasks saved for a rejected request", saved)
+	}
+}
+
+// newBusyManager starts a VM manager advertising runtimes whose health check
+// answers every request with 503 and Retry-After: 1 while busy() reports true
+func newBusyManager(t *testing.T, runtimes []string, busy func() bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if busy() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/health":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "healthy", "runtimes": runtimes})
+		case "/vms":
+			json.NewEncoder(w).Encode(map[string]interface{}{"vms": []interface{}{}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBusyManagerIsRetriedUntilItAccepts(t *testing.T) {
+	var requests int32
+	manager := newBusyManager(t, nil, func() bool {
+		// Busy for the first two requests only
+		return atomic.AddInt32(&requests, 1) <= 2
+	})
+	setupTest(t, manager.URL)
+
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx"})
+	task := waitForStatus(t, id, TaskStatusPlanned)
+	if task.VMManager != manager.URL {
+		t.Errorf("VMManager = %q, want %q", task.VMManager, manager.URL)
+	}
+	// Two refused health checks, then one accepted probe of /health and /vms
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("manager was probed %d times, want 4", n)
+	}
+}
+
+func TestBusyManagerWithoutRuntimeFailsFast(t *testing.T) {
+	var busy int32
+	manager := newBusyManager(t, []string{"kvm"}, func() bool { return atomic.LoadInt32(&busy) == 1 })
+	setupTest(t, manager.URL)
+
+	// Learn the manager's runtimes while it is healthy, then make it busy
+	scheduler.probeAll()
+	atomic.StoreInt32(&busy, 1)
+
+	_, err := scheduler.SelectHost(placement{runtime: "gvisor"})
+	var busyErr *busyError
+	if err == nil || errors.As(err, &busyErr) {
+		t.Fatalf("gvisor placement = %v, want a non-retryable error", err)
+	}
+	if !strings.Contains(err.Error(), "does not provide runtime gvisor") {
+		t.Errorf("error = %q", err)
+	}
+
+	// A runtime the busy manager does provide is still worth waiting for
+	if _, err := scheduler.SelectHost(placement{runtime: "kvm"}); !errors.As(err, &busyErr) {
+		t.Errorf("kvm placement = %v, want a busy error", err)
+	}
+
+	start := time.Now()
+	id := submitTask(t, map[string]interface{}{"instruction": "install nginx", "runtime": "gvisor"})
+	waitForStatus(t, id, TaskStatusFailed)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gvisor task took %s to fail, want no waiting", elapsed)
+	}
+}
+
+func TestBusyManagerNeverSeenHealthyMayProvideRuntime(t *testing.T) {
+	manager := newBusyManager(t, nil, func() bool { return true })
+	s := newVMScheduler([]string{manager.URL})
+
+	var busyErr *busyError
+	if _, err := s.SelectHost(placement{runtime: "gvisor"}); !errors.As(err, &busyErr) {
+		t.Errorf("SelectHost = %v, want a busy error", err)
+	}
+}
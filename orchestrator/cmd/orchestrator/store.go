@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	_ "github.com/lib/pq"
+)
+
+// ErrTaskNotFound is returned by a Store when no task has the given ID
+var ErrTaskNotFound = errors.New("task not found")
+
+// Store persists tasks so they survive restarts and can be shared between
+// orchestrator replicas. TaskStore remains the in-process cache in front of it.
+type Store interface {
+	SaveTask(task *Task) error
+	LoadTask(id string) (*Task, error)
+	DeleteTask(id string) error
+	ListTasks() ([]*Task, error)
+}
+
+// newStoreFromEnv selects the store backend from STORE_BACKEND/DATABASE_URL
+func newStoreFromEnv() (Store, error) {
+	backend := os.Getenv("STORE_BACKEND")
+	switch backend {
+	case "", "memory":
+		return newMemoryStore(), nil
+	case "postgres":
+		databaseURL := os.Getenv("DATABASE_URL")
+		if databaseURL == "" {
+			return nil, errors.New("DATABASE_URL is required for the postgres store")
+		}
+		return newPostgresStore(databaseURL)
+	}
+	return nil, fmt.Errorf("unknown STORE_BACKEND %q", backend)
+}
+
+// memoryStore keeps tasks in process memory only
+type memoryStore struct {
+	mutex sync.RWMutex
+	tasks map[string]Task
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tasks: make(map[string]Task)}
+}
+
+func (s *memoryStore) SaveTask(task *Task) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tasks[task.ID] = *task
+	return nil
+}
+
+func (s *memoryStore) LoadTask(id string) (*Task, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return &task, nil
+}
+
+func (s *memoryStore) DeleteTask(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tasks, id)
+	return nil
+}
+
+func (s *memoryStore) ListTasks() ([]*Task, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tasks := make([]*Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		task := task
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+// postgresStore keeps each task as a JSON document in a single table
+type postgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(databaseURL string) (*postgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS tasks (
+		id         TEXT PRIMARY KEY,
+		data       JSONB NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tasks table: %v", err)
+	}
+
+	return &postgresStore{db: db}, nil
+}
+
+func (s *postgresStore) SaveTask(task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO tasks (id, data, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`,
+		task.ID, data, task.UpdatedAt)
+	return err
+}
+
+func (s *postgresStore) LoadTask(id string) (*Task, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM tasks WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *postgresStore) DeleteTask(id string) error {
+	_, err := s.db.Exec(`DELETE FROM tasks WHERE id = $1`, id)
+	return err
+}
+
+func (s *postgresStore) ListTasks() ([]*Task, error) {
+	rows, err := s.db.Query(`SELECT data FROM tasks ORDER BY updated_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*Task
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, rows.Err()
+}
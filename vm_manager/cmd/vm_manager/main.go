@@ -1,10 +1,11 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -17,17 +18,49 @@ type VM struct {
 	IPAddress []string `json:"ipAddress,omitempty"`
 }
 
+// runtimes lists the isolation runtimes this manager's templates provide. The
+// orchestrator routes tasks with a runtime hint to managers advertising it.
+var runtimes = []string{"kvm"}
+
 func main() {
 	log.Println("Starting VM Manager service")
 
-	router := mux.NewRouter()
-	router.HandleFunc("/vms", listVMsHandler).Methods("GET")
-	router.HandleFunc("/vms/{id}", getVMHandler).Methods("GET")
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	if value := os.Getenv("VM_RUNTIMES"); value != "" {
+		runtimes = nil
+		for _, rt := range strings.Split(value, ",") {
+			if rt = strings.ToLower(strings.TrimSpace(rt)); rt != "" {
+				runtimes = append(runtimes, rt)
+			}
+		}
+	}
+	log.Printf("Supported runtimes: %s", strings.Join(runtimes, ", "))
+
+	// ROUTE_PREFIX mounts every route under a base path, e.g. /vm-manager
+	prefix := normalizePrefix(os.Getenv("ROUTE_PREFIX"))
+	if prefix != "" {
+		log.Printf("Serving routes under %s", prefix)
+	}
+	router := newRouter(prefix)
 
 	port := "8083"
 	log.Printf("VM Manager listening on port %s", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), router))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), stripTrailingSlash(router)))
+}
+
+// newRouter registers the VM Manager routes under prefix, which must already
+// be normalized
+func newRouter(prefix string) *mux.Router {
+	router := mux.NewRouter()
+	routes := router
+	if prefix != "" {
+		routes = router.PathPrefix(prefix).Subrouter()
+	}
+	routes.HandleFunc("/vms", listVMsHandler).Methods("GET")
+	routes.HandleFunc("/vms/{id}", getVMHandler).Methods("GET")
+	routes.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	routes.HandleFunc("/version", versionHandler).Methods("GET")
+	configureRouter(router)
+	return router
 }
 
 func listVMsHandler(w http.ResponseWriter, r *http.Request) {
@@ -36,8 +69,7 @@ func listVMsHandler(w http.ResponseWriter, r *http.Request) {
 		{ID: "vm2", Name: "openSUSE-2", Status: "stopped"},
 	}
 	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeResponse(w, r, map[string]interface{}{
 		"vms": vms,
 	})
 }
@@ -48,11 +80,14 @@ func getVMHandler(w http.ResponseWriter, r *http.Request) {
 	
 	vm := VM{ID: id, Name: "openSUSE-" + id, Status: "running", IPAddress: []string{"192.168.122.100"}}
 	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(vm)
+	writeResponse(w, r, vm)
 }
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	writeResponse(w, r, map[string]interface{}{
+		"status":     "healthy",
+		"version":    version,
+		"git_commit": gitCommit,
+		"runtimes":   runtimes,
+	})
 }
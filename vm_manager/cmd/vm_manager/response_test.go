@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// getVM calls getVMHandler for id with the given Accept header
+func getVM(t *testing.T, id, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/vms/"+id, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	getVMHandler(rec, req)
+	return rec
+}
+
+func TestGetVMHandlerYAML(t *testing.T) {
+	for _, accept := range []string{"application/yaml", "text/yaml", "application/json;q=0.5, application/x-yaml"} {
+		rec := getVM(t, "vm1", accept)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Accept %q: status %d", accept, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+			t.Errorf("Accept %q: Content-Type = %q", accept, ct)
+		}
+
+		// YAML keys follow the JSON field names, so decode into a map
+		var fields map[string]interface{}
+		if err := yaml.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+			t.Fatalf("Accept %q: invalid YAML: %v\n%s", accept, err, rec.Body.String())
+		}
+		if fields["id"] != "vm1" || fields["name"] != "openSUSE-vm1" || fields["status"] != "running" {
+			t.Errorf("Accept %q: fields = %v", accept, fields)
+		}
+		if ips, _ := fields["ipAddress"].([]interface{}); len(ips) != 1 || ips[0] != "192.168.122.100" {
+			t.Errorf("Accept %q: ipAddress = %v", accept, fields["ipAddress"])
+		}
+	}
+}
+
+func TestGetVMHandlerDefaultsToJSON(t *testing.T) {
+	for _, accept := range []string{"", "application/json", "*/*"} {
+		rec := getVM(t, "vm1", accept)
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Accept %q: Content-Type = %q", accept, ct)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve sends a request through the router the way main does
+func serve(router http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	stripTrailingSlash(router).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	for _, prefix := range []string{"", "/vm-manager"} {
+		rec := serve(newRouter(prefix), http.MethodPost, prefix+"/vms")
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("prefix %q: status %d, want 405", prefix, rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != "GET" {
+			t.Errorf("prefix %q: Allow = %q, want GET", prefix, allow)
+		}
+		var body struct {
+			Error          string   `json:"error"`
+			Method         string   `json:"method"`
+			AllowedMethods []string `json:"allowed_methods"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("prefix %q: body is not JSON: %v", prefix, err)
+		}
+		if body.Error != "method not allowed" || body.Method != http.MethodPost || len(body.AllowedMethods) != 1 {
+			t.Errorf("prefix %q: body = %+v", prefix, body)
+		}
+	}
+}
+
+func TestNotFoundIsJSON(t *testing.T) {
+	rec := serve(newRouter(""), http.MethodGet, "/nope")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body["error"] != "not found" || body["path"] != "/nope" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	for _, path := range []string{"/vms/", "/vms/vm1/", "/health/"} {
+		if rec := serve(newRouter(""), http.MethodGet, path); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", path, rec.Code)
+		}
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	for in, want := range map[string]string{"": "", "/": "", "vm-manager": "/vm-manager", " /vm-manager/ ": "/vm-manager", "a/b/": "/a/b"} {
+		if got := normalizePrefix(in); got != want {
+			t.Errorf("normalizePrefix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	router := newRouter(normalizePrefix("vm-manager/"))
+
+	for _, path := range []string{"/vm-manager/vms", "/vm-manager/vms/vm1", "/vm-manager/health", "/vm-manager/version", "/vm-manager/vms/"} {
+		if rec := serve(router, http.MethodGet, path); rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", path, rec.Code)
+		}
+	}
+	for _, path := range []string{"/vms", "/vms/vm1", "/health", "/version", "/vm-manager"} {
+		if rec := serve(router, http.MethodGet, path); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, rec.Code)
+		}
+	}
+}
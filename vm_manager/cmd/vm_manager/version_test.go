@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+// getVersion returns the decoded GET /version response
+func getVersion(t *testing.T) buildInfo {
+	t.Helper()
+	rec := serve(newRouter(""), http.MethodGet, "/version")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /version returned %d", rec.Code)
+	}
+	var info buildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestVersionDefaultsToDev(t *testing.T) {
+	want := buildInfo{Version: "dev", GitCommit: "dev", BuildDate: "dev", GoVersion: runtime.Version()}
+	if info := getVersion(t); info != want {
+		t.Errorf("GET /version = %+v, want %+v", info, want)
+	}
+}
+
+func TestVersionReportsInjectedValues(t *testing.T) {
+	// Stand in for -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildDate=..."
+	saved := []string{version, gitCommit, buildDate}
+	version, gitCommit, buildDate = "1.2.0", "abc1234", "2024-05-01T12:00:00Z"
+	t.Cleanup(func() { version, gitCommit, buildDate = saved[0], saved[1], saved[2] })
+
+	want := buildInfo{Version: "1.2.0", GitCommit: "abc1234", BuildDate: "2024-05-01T12:00:00Z", GoVersion: runtime.Version()}
+	if info := getVersion(t); info != want {
+		t.Errorf("GET /version = %+v, want %+v", info, want)
+	}
+
+	rec := serve(newRouter(""), http.MethodGet, "/health")
+	var health map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health["version"] != "1.2.0" || health["git_commit"] != "abc1234" {
+		t.Errorf("GET /health = %v, want the injected version", health)
+	}
+}
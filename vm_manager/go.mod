@@ -3,3 +3,5 @@ module vm_manager
 go 1.19
 
 require github.com/gorilla/mux v1.8.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,125 @@
+// Package vmclient provides a typed client for the VM Manager HTTP API.
+package vmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sentinel errors returned (wrapped in an *APIError) for well-known statuses
+var (
+	ErrNotFound    = errors.New("vm not found")
+	ErrConflict    = errors.New("vm conflict")
+	ErrUnavailable = errors.New("vm manager unavailable")
+)
+
+// VM represents a virtual machine as returned by the VM Manager
+type VM struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	IPAddress []string `json:"ipAddress,omitempty"`
+}
+
+// APIError describes a non-2xx response from the VM Manager
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vm manager returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap maps the status code to one of the sentinel errors so callers can
+// use errors.Is.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Client talks to a single VM Manager instance
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// Token, when set, is sent as a bearer token on every request.
+	Token string
+}
+
+// New creates a client for the VM Manager at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ListVMs returns all VMs known to the manager
+func (c *Client) ListVMs(ctx context.Context) ([]VM, error) {
+	var resp struct {
+		VMs []VM `json:"vms"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/vms", &resp); err != nil {
+		return nil, err
+	}
+	return resp.VMs, nil
+}
+
+// GetVM returns a single VM by ID
+func (c *Client) GetVM(ctx context.Context, id string) (*VM, error) {
+	var vm VM
+	if err := c.do(ctx, http.MethodGet, "/vms/"+url.PathEscape(id), &vm); err != nil {
+		return nil, err
+	}
+	return &vm, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
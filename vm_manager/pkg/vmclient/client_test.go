@@ -0,0 +1,100 @@
+package vmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL + "/")
+}
+
+func TestListVMs(t *testing.T) {
+	client := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/vms" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"vms": []VM{
+				{ID: "vm1", Name: "openSUSE-1", Status: "running", IPAddress: []string{"192.168.122.100"}},
+				{ID: "vm2", Name: "openSUSE-2", Status: "stopped"},
+			},
+		})
+	})
+
+	vms, err := client.ListVMs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) != 2 || vms[0].ID != "vm1" || vms[0].IPAddress[0] != "192.168.122.100" || vms[1].Status != "stopped" {
+		t.Errorf("ListVMs = %+v", vms)
+	}
+}
+
+func TestGetVMEscapesIDAndSendsToken(t *testing.T) {
+	client := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/vms/a%2Fb" {
+			t.Errorf("path = %q", r.URL.EscapedPath())
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		json.NewEncoder(w).Encode(VM{ID: "a/b", Name: "odd", Status: "running"})
+	})
+	client.Token = "secret"
+
+	vm, err := client.GetVM(context.Background(), "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.ID != "a/b" || vm.Name != "odd" {
+		t.Errorf("GetVM = %+v", vm)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusServiceUnavailable, ErrUnavailable},
+		{http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		client := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", tt.status)
+		})
+
+		_, err := client.GetVM(context.Background(), "vm1")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("status %d: error %v is not an *APIError", tt.status, err)
+		}
+		if apiErr.StatusCode != tt.status || apiErr.Message != "nope" {
+			t.Errorf("status %d: APIError = %+v", tt.status, apiErr)
+		}
+		for _, sentinel := range []error{ErrNotFound, ErrConflict, ErrUnavailable} {
+			if got, want := errors.Is(err, sentinel), sentinel == tt.want; got != want {
+				t.Errorf("status %d: errors.Is(err, %v) = %v, want %v", tt.status, sentinel, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	client := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{not json"))
+	})
+	if _, err := client.ListVMs(context.Background()); err == nil {
+		t.Fatal("expected a decode error")
+	}
+}